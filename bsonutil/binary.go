@@ -0,0 +1,140 @@
+package bsonutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bson types
+const (
+	BINARY_TYPENAME = "bson{binary}"
+)
+
+// Binary mongo, UUID is binary of subtype 4
+type Binary struct {
+	B primitive.Binary
+}
+
+var binaryMethods = map[string]lua.LGFunction{
+	"base64":  binaryBase64Method,
+	"data":    binaryDataMethod,
+	"hex":     binaryHexMethod,
+	"subtype": binarySubtypeMethod,
+}
+
+// NewBinary new Binary for glua, subtype defaults to generic binary
+func NewBinary(L *lua.LState) int {
+	data := L.CheckString(1)
+	subtype := L.OptInt(2, int(bsontype.BinaryGeneric))
+	if subtype < 0 || subtype > 0xff {
+		L.ArgError(2, "invalid subtype")
+		return 0
+	}
+
+	L.Push(LBinary(L, primitive.Binary{Subtype: byte(subtype), Data: []byte(data)}))
+	return 1
+}
+
+// NewUUID new UUID for glua, from string or random (version 4)
+func NewUUID(L *lua.LState) int {
+	data := make([]byte, 16)
+	if str := L.OptString(1, ""); str != "" {
+		s := strings.Replace(str, "-", "", -1)
+		if len(s) != 32 {
+			L.ArgError(1, "invalid format")
+			return 0
+		}
+		if _, err := hex.Decode(data, []byte(s)); err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+	} else {
+		if _, err := rand.Read(data); err != nil {
+			L.RaiseError(err.Error())
+			return 0
+		}
+		data[6] = data[6]&0x0f | 0x40 // version 4
+		data[8] = data[8]&0x3f | 0x80 // variant 10
+	}
+
+	L.Push(LBinary(L, primitive.Binary{Subtype: bsontype.BinaryUUID, Data: data}))
+	return 1
+}
+
+// LBinary creates Binary value for glua
+func LBinary(L *lua.LState, b primitive.Binary) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Binary{B: b}
+	L.SetMetatable(ud, L.GetTypeMetatable(BINARY_TYPENAME))
+	return ud
+}
+
+func checkBinary(L *lua.LState, idx int) *Binary {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Binary); ok {
+		return v
+	}
+	L.ArgError(idx, "bson binary expected")
+	return nil
+}
+
+// isUUID reports whether b is a well formed UUID
+func (b *Binary) isUUID() bool {
+	return b.B.Subtype == bsontype.BinaryUUID && len(b.B.Data) == 16
+}
+
+func binaryBase64Method(L *lua.LState) int {
+	b := checkBinary(L, 1)
+
+	L.Push(lua.LString(base64.StdEncoding.EncodeToString(b.B.Data)))
+	return 1
+}
+
+func binaryDataMethod(L *lua.LState) int {
+	b := checkBinary(L, 1)
+
+	L.Push(lua.LString(b.B.Data))
+	return 1
+}
+
+func binaryHexMethod(L *lua.LState) int {
+	b := checkBinary(L, 1)
+
+	L.Push(lua.LString(hex.EncodeToString(b.B.Data)))
+	return 1
+}
+
+func binarySubtypeMethod(L *lua.LState) int {
+	b := checkBinary(L, 1)
+
+	L.Push(lua.LNumber(b.B.Subtype))
+	return 1
+}
+
+func binaryToStringMethod(L *lua.LState) int {
+	b := checkBinary(L, 1)
+
+	if b.isUUID() {
+		h := hex.EncodeToString(b.B.Data)
+		L.Push(lua.LString(fmt.Sprintf("UUID(\"%s-%s-%s-%s-%s\")", h[:8], h[8:12], h[12:16], h[16:20], h[20:])))
+		return 1
+	}
+	L.Push(lua.LString(fmt.Sprintf("Binary(\"%s\", %d)", base64.StdEncoding.EncodeToString(b.B.Data), b.B.Subtype)))
+	return 1
+}
+
+func binaryEqMethod(L *lua.LState) int {
+	b1 := checkBinary(L, 1)
+	b2 := checkBinary(L, 2)
+
+	L.Push(lua.LBool(b1.B.Subtype == b2.B.Subtype && bytes.Equal(b1.B.Data, b2.B.Data)))
+	return 1
+}
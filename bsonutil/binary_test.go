@@ -0,0 +1,63 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBinary(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local b = bson.Binary("foo", 0x80)
+		local u = bson.UUID("0e0bb3a5-9e5e-4b43-ae46-0f2bd1d3c2fb")
+		local r = bson.UUID()
+		return b:hex(), b:base64(), b:subtype(), b:data(), tostring(b),
+		  u:subtype(), tostring(u), u == bson.UUID("0e0bb3a59e5e4b43ae460f2bd1d3c2fb"),
+		  b == bson.Binary("foo"), r:hex():sub(13, 13), #r:data()
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(11, L.GetTop())
+	assert.Equal("666f6f", L.ToString(1))
+	assert.Equal("Zm9v", L.ToString(2))
+	assert.Equal(lua.LNumber(0x80), L.Get(3))
+	assert.Equal("foo", L.ToString(4))
+	assert.Equal(`Binary("Zm9v", 128)`, L.ToString(5))
+	assert.Equal(lua.LNumber(4), L.Get(6))
+	assert.Equal(`UUID("0e0bb3a5-9e5e-4b43-ae46-0f2bd1d3c2fb")`, L.ToString(7))
+	assert.Equal(lua.LTrue, L.Get(8))
+	assert.Equal(lua.LFalse, L.Get(9))
+	assert.Equal("4", L.ToString(10))
+	assert.Equal(lua.LNumber(16), L.Get(11))
+
+	for _, script := range []string{
+		`return require('bson').UUID("xyz")`,
+		`return require('bson').Binary("foo", 256)`,
+	} {
+		assert.Error(L.DoString(script), script)
+	}
+}
+
+func TestBinaryValue(t *testing.T) {
+	assert := assert.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	b := primitive.Binary{Subtype: 4, Data: make([]byte, 16)}
+	lv := ToLuaValue(L, b)
+	assert.Equal(`UUID("00000000-0000-0000-0000-000000000000")`, L.ToStringMeta(lv).String())
+	assert.Equal(b, Value(L, lv))
+}
@@ -28,22 +28,42 @@ type Timestamp struct {
 type Null struct {
 }
 
-var dateTimeMethods = map[string]lua.LGFunction{}
-var timestampMethods = map[string]lua.LGFunction{}
 var nullMethods = map[string]lua.LGFunction{}
 
-// NewDateTime new DateTime for glua
+// NewDateTime new DateTime for glua, from milliseconds, ISO-8601 string or
+// os.date("*t") table
 func NewDateTime(L *lua.LState) int {
-	dt := L.OptInt64(1, 0)
+	var dt primitive.DateTime
+	switch v := L.Get(1).(type) {
+	case lua.LString:
+		t, err := parseISODateTime(string(v))
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		dt = primitive.NewDateTimeFromTime(t)
+	case *lua.LTable:
+		t, err := dateTableToTime(v)
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		dt = primitive.NewDateTimeFromTime(t)
+	default:
+		dt = primitive.DateTime(L.OptInt64(1, 0))
+	}
 
 	ud := L.NewUserData()
-	ud.Value = &DateTime{DT: primitive.DateTime(dt)}
+	ud.Value = &DateTime{DT: dt}
 	L.SetMetatable(ud, L.GetTypeMetatable(DATETIME_TYPENAME))
 	L.Push(ud)
 	return 1
 }
 
-// NewTimestamp new Timestamp for glua
+// NewTimestamp new Timestamp for glua, Timestamp() is the zero timestamp
+// which the server replaces with the current one when it is inserted as a
+// top-level field, see
+// https://docs.mongodb.com/manual/reference/bson-types/#timestamps
 func NewTimestamp(L *lua.LState) int {
 	switch L.GetTop() {
 	case 0, 2:
@@ -52,20 +72,11 @@ func NewTimestamp(L *lua.LState) int {
 		L.ArgError(1, "Timestamp needs 0 or 2 arguments")
 		return 0
 	}
+	// the most significant 32 bits are a time_t value (seconds since the Unix epoch),
+	// the least significant 32 bits are an incrementing ordinal for operations within a given second.
 	t := L.OptInt(1, 0)
 	i := L.OptInt(2, 0)
 
-	if t == 0 && i == 0 {
-		// mongodb will generates a new timestamp when saving documents
-		// https://docs.mongodb.com/manual/reference/bson-types/#timestamps
-		//
-		// now := time.Now()
-		// the most significant 32 bits are a time_t value (seconds since the Unix epoch)
-		// t = int(now.Unix())
-		// the least significant 32 bits are an incrementing ordinal for operations within a given second.
-		// i = 1
-	}
-
 	ud := L.NewUserData()
 	ud.Value = &Timestamp{Ts: primitive.Timestamp{T: uint32(t), I: uint32(i)}}
 	L.SetMetatable(ud, L.GetTypeMetatable(TIMESTAMP_TYPENAME))
@@ -11,9 +11,50 @@ import (
 var ErrInvalidBSON = errors.New("invalid BSON")
 
 var exports = map[string]lua.LGFunction{
-	"ObjectID":  NewObjectID,
-	"DateTime":  NewDateTime,
-	"Timestamp": NewTimestamp,
+	"ObjectID":      NewObjectID,
+	"DateTime":      NewDateTime,
+	"Timestamp":     NewTimestamp,
+	"Doc":           NewDoc,
+	"Int32":         NewInt32,
+	"Int64":         NewInt64,
+	"Double":        NewDouble,
+	"Decimal128":    NewDecimal128,
+	"Binary":        NewBinary,
+	"UUID":          NewUUID,
+	"Regex":         NewRegex,
+	"Code":          NewCode,
+	"CodeWithScope": NewCodeWithScope,
+	"DBPointer":     NewDBPointer,
+	"Symbol":        NewSymbol,
+}
+
+// typeFuncs are the functions set on the type constructors
+var typeFuncs = map[string]map[string]lua.LGFunction{
+	"DateTime": dateTimeFuncs,
+	"ObjectID": objectIDFuncs,
+	"Regex":    regexFuncs,
+}
+
+// SetTypeFuncs replaces the type constructors of mod with callable tables
+// holding the type functions, e.g. mongo.Regex.fromLuaPattern
+func SetTypeFuncs(L *lua.LState, mod *lua.LTable) {
+	for name, funcs := range typeFuncs {
+		ctor, ok := L.GetField(mod, name).(*lua.LFunction)
+		if !ok {
+			continue
+		}
+		tb := L.SetFuncs(L.NewTable(), funcs)
+		mt := L.NewTable()
+		// drop the table itself from the arguments
+		L.SetField(mt, "__call", L.NewFunction(func(L *lua.LState) int {
+			L.Remove(1)
+			L.Insert(ctor, 1)
+			L.Call(L.GetTop()-1, lua.MultRet)
+			return L.GetTop()
+		}))
+		L.SetMetatable(tb, mt)
+		L.SetField(mod, name, tb)
+	}
 }
 
 // RegisterType registers bson types
@@ -21,22 +62,92 @@ func RegisterType(L *lua.LState) {
 	mtObjectID := L.NewTypeMetatable(OBJECTID_TYPENAME)
 	L.SetField(mtObjectID, "__index", L.SetFuncs(L.NewTable(), objectIDMethods))
 	L.SetField(mtObjectID, "__eq", L.NewFunction(objectIDEqMethod))
+	L.SetField(mtObjectID, "__lt", L.NewFunction(objectIDLtMethod))
+	L.SetField(mtObjectID, "__le", L.NewFunction(objectIDLeMethod))
 	L.SetField(mtObjectID, "__tostring", L.NewFunction(objectIDToStringMethod))
 
 	mtDateTime := L.NewTypeMetatable(DATETIME_TYPENAME)
 	L.SetField(mtDateTime, "__index", L.SetFuncs(L.NewTable(), dateTimeMethods))
 	L.SetField(mtDateTime, "__eq", L.NewFunction(dateTimeEqMethod))
+	L.SetField(mtDateTime, "__lt", L.NewFunction(dateTimeLtMethod))
+	L.SetField(mtDateTime, "__le", L.NewFunction(dateTimeLeMethod))
+	L.SetField(mtDateTime, "__sub", L.NewFunction(dateTimeSubMethod))
 	L.SetField(mtDateTime, "__tostring", L.NewFunction(dateTimeToStringMethod))
 
 	mtTimestamp := L.NewTypeMetatable(TIMESTAMP_TYPENAME)
 	L.SetField(mtTimestamp, "__index", L.SetFuncs(L.NewTable(), timestampMethods))
 	L.SetField(mtTimestamp, "__eq", L.NewFunction(timestampEqMethod))
+	L.SetField(mtTimestamp, "__lt", L.NewFunction(timestampLtMethod))
+	L.SetField(mtTimestamp, "__le", L.NewFunction(timestampLeMethod))
 	L.SetField(mtTimestamp, "__tostring", L.NewFunction(timestampToStringMethod))
 
 	mtNull := L.NewTypeMetatable(NULL_TYPENAME)
 	L.SetField(mtNull, "__index", L.SetFuncs(L.NewTable(), nullMethods))
 	L.SetField(mtNull, "__eq", L.NewFunction(nullEqMethod))
 	L.SetField(mtNull, "__tostring", L.NewFunction(nullToStringMethod))
+
+	mtDoc := L.NewTypeMetatable(DOC_TYPENAME)
+	L.SetField(mtDoc, "__index", L.NewFunction(docIndexMethod))
+	L.SetField(mtDoc, "__newindex", L.NewFunction(docNewIndexMethod))
+	L.SetField(mtDoc, "__len", L.NewFunction(docLenMethod))
+	L.SetField(mtDoc, "__call", L.NewFunction(docCallMethod))
+	L.SetField(mtDoc, "__tostring", L.NewFunction(docToStringMethod))
+
+	mtBinary := L.NewTypeMetatable(BINARY_TYPENAME)
+	L.SetField(mtBinary, "__index", L.SetFuncs(L.NewTable(), binaryMethods))
+	L.SetField(mtBinary, "__eq", L.NewFunction(binaryEqMethod))
+	L.SetField(mtBinary, "__tostring", L.NewFunction(binaryToStringMethod))
+
+	mtRegex := L.NewTypeMetatable(REGEX_TYPENAME)
+	L.SetField(mtRegex, "__index", L.SetFuncs(L.NewTable(), regexMethods))
+	L.SetField(mtRegex, "__eq", L.NewFunction(regexEqMethod))
+	L.SetField(mtRegex, "__tostring", L.NewFunction(regexToStringMethod))
+
+	singletonEq := L.NewFunction(singletonEqMethod)
+	mtMinKey := L.NewTypeMetatable(MINKEY_TYPENAME)
+	L.SetField(mtMinKey, "__eq", singletonEq)
+	L.SetField(mtMinKey, "__tostring", L.NewFunction(minKeyToStringMethod))
+
+	mtMaxKey := L.NewTypeMetatable(MAXKEY_TYPENAME)
+	L.SetField(mtMaxKey, "__eq", singletonEq)
+	L.SetField(mtMaxKey, "__tostring", L.NewFunction(maxKeyToStringMethod))
+
+	mtUndefined := L.NewTypeMetatable(UNDEFINED_TYPENAME)
+	L.SetField(mtUndefined, "__eq", singletonEq)
+	L.SetField(mtUndefined, "__tostring", L.NewFunction(undefinedToStringMethod))
+
+	mtCode := L.NewTypeMetatable(CODE_TYPENAME)
+	L.SetField(mtCode, "__index", L.SetFuncs(L.NewTable(), codeMethods))
+	L.SetField(mtCode, "__eq", L.NewFunction(codeEqMethod))
+	L.SetField(mtCode, "__tostring", L.NewFunction(codeToStringMethod))
+
+	mtCodeWithScope := L.NewTypeMetatable(CODEWITHSCOPE_TYPENAME)
+	L.SetField(mtCodeWithScope, "__index", L.SetFuncs(L.NewTable(), codeWithScopeMethods))
+	L.SetField(mtCodeWithScope, "__eq", L.NewFunction(codeWithScopeEqMethod))
+	L.SetField(mtCodeWithScope, "__tostring", L.NewFunction(codeWithScopeToStringMethod))
+
+	mtDBPointer := L.NewTypeMetatable(DBPOINTER_TYPENAME)
+	L.SetField(mtDBPointer, "__index", L.SetFuncs(L.NewTable(), dbPointerMethods))
+	L.SetField(mtDBPointer, "__eq", L.NewFunction(dbPointerEqMethod))
+	L.SetField(mtDBPointer, "__tostring", L.NewFunction(dbPointerToStringMethod))
+
+	mtSymbol := L.NewTypeMetatable(SYMBOL_TYPENAME)
+	L.SetField(mtSymbol, "__index", L.SetFuncs(L.NewTable(), symbolMethods))
+	L.SetField(mtSymbol, "__eq", L.NewFunction(symbolEqMethod))
+	L.SetField(mtSymbol, "__tostring", L.NewFunction(symbolToStringMethod))
+
+	registerNumberTypes(L)
+
+	mtDecimal128 := L.NewTypeMetatable(DECIMAL128_TYPENAME)
+	L.SetField(mtDecimal128, "__index", L.SetFuncs(L.NewTable(), decimal128Methods))
+	L.SetField(mtDecimal128, "__add", L.NewFunction(decimal128Arith(decimalAdd)))
+	L.SetField(mtDecimal128, "__sub", L.NewFunction(decimal128Arith(decimalSub)))
+	L.SetField(mtDecimal128, "__mul", L.NewFunction(decimal128Arith(decimalMul)))
+	L.SetField(mtDecimal128, "__div", L.NewFunction(decimal128Arith(decimalDiv)))
+	L.SetField(mtDecimal128, "__eq", L.NewFunction(decimal128EqMethod))
+	L.SetField(mtDecimal128, "__lt", L.NewFunction(decimal128LtMethod))
+	L.SetField(mtDecimal128, "__le", L.NewFunction(decimal128LeMethod))
+	L.SetField(mtDecimal128, "__tostring", L.NewFunction(decimal128ToStringMetaMethod))
 }
 
 // UnmarshalBSON unmarshals extended json to bson
@@ -75,6 +186,12 @@ func CastBSON(L *lua.LState, idx int) interface{} {
 			}
 		}
 		return val
+	case lua.LTUserData:
+		if val, ok := GetValue(L, idx).(bson.D); ok {
+			return val
+		}
+		L.ArgError(idx, "string, table or doc expected")
+		return nil
 	default:
 		L.ArgError(idx, "string or table expected")
 		return nil
@@ -89,3 +206,20 @@ func ToBSON(L *lua.LState, idx int) interface{} {
 	}
 	return CastBSON(L, idx)
 }
+
+// CastOrderedBSON casts glua value to bson like CastBSON, documents keep the
+// key order, e.g. for commands
+func CastOrderedBSON(L *lua.LState, idx int) interface{} {
+	lv := L.Get(idx)
+	if lv.Type() != lua.LTTable {
+		return CastBSON(L, idx)
+	}
+	val := OrderedValue(L, lv)
+	if arr, ok := val.([]interface{}); ok {
+		if len(arr) == 0 {
+			// empty doc treats as {} instead of []
+			return bson.D{}
+		}
+	}
+	return val
+}
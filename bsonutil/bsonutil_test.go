@@ -12,6 +12,7 @@ import (
 
 func Loader(L *lua.LState) int {
 	mod := L.SetFuncs(L.NewTable(), exports)
+	SetTypeFuncs(L, mod)
 	L.Push(mod)
 
 	L.SetField(mod, "_DEBUG", lua.LBool(false))
@@ -21,6 +22,9 @@ func Loader(L *lua.LState) int {
 
 	// consts, after type registered
 	L.SetField(mod, "Null", LNull(L))
+	L.SetField(mod, "MinKey", LMinKey(L))
+	L.SetField(mod, "MaxKey", LMaxKey(L))
+	L.SetField(mod, "Undefined", LUndefined(L))
 
 	return 1
 }
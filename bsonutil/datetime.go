@@ -0,0 +1,226 @@
+package bsonutil
+
+import (
+	"fmt"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// isoLayouts are the accepted ISO-8601 layouts, time without zone is UTC
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+var dateTimeMethods = map[string]lua.LGFunction{
+	"addDuration": dateTimeAddDurationMethod,
+	"format":      dateTimeFormatMethod,
+	"toISOString": dateTimeToISOStringMethod,
+	"toTable":     dateTimeToTableMethod,
+	"truncate":    dateTimeTruncateMethod,
+	"unixMillis":  dateTimeUnixMillisMethod,
+}
+
+var dateTimeFuncs = map[string]lua.LGFunction{
+	"now": dateTimeNowFunc,
+}
+
+// parseISODateTime parses ISO-8601 string
+func parseISODateTime(s string) (time.Time, error) {
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid ISO-8601 date %q", s)
+}
+
+// dateTableField gets integer field of os.date table
+func dateTableField(tb *lua.LTable, key string, def int) (int, error) {
+	switch v := tb.RawGetString(key).(type) {
+	case lua.LNumber:
+		return int(v), nil
+	case *lua.LNilType:
+		if def >= 0 {
+			return def, nil
+		}
+		return 0, fmt.Errorf("field '%s' missing in date table", key)
+	}
+	return 0, fmt.Errorf("field '%s' is not a number", key)
+}
+
+// dateTableToTime converts os.date("*t") table in local time like os.time,
+// hour defaults to 12
+func dateTableToTime(tb *lua.LTable) (time.Time, error) {
+	var fields [6]int
+	for i, f := range []struct {
+		key string
+		def int
+	}{{"year", -1}, {"month", -1}, {"day", -1}, {"hour", 12}, {"min", 0}, {"sec", 0}} {
+		v, err := dateTableField(tb, f.key, f.def)
+		if err != nil {
+			return time.Time{}, err
+		}
+		fields[i] = v
+	}
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, time.Local), nil
+}
+
+// toLocation loads time zone by name, UTC by default
+func toLocation(name string) (*time.Location, error) {
+	switch name {
+	case "", "UTC":
+		return time.UTC, nil
+	case "Local":
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// toDuration converts go duration string or milliseconds
+func toDuration(lv lua.LValue) (time.Duration, error) {
+	switch v := lv.(type) {
+	case lua.LNumber:
+		return time.Duration(float64(v) * float64(time.Millisecond)), nil
+	case lua.LString:
+		return time.ParseDuration(string(v))
+	}
+	return 0, fmt.Errorf("duration expected, got %s", lv.Type())
+}
+
+func dateTimeNowFunc(L *lua.LState) int {
+	L.Push(LDateTime(L, primitive.NewDateTimeFromTime(time.Now())))
+	return 1
+}
+
+func dateTimeAddDurationMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+	d, err := toDuration(L.Get(2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	L.Push(LDateTime(L, primitive.NewDateTimeFromTime(dateTime.DT.Time().Add(d))))
+	return 1
+}
+
+// dateTimeFormatMethod formats with go layout in time zone, UTC by default
+func dateTimeFormatMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+	layout := L.CheckString(2)
+	loc, err := toLocation(L.OptString(3, ""))
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	L.Push(lua.LString(dateTime.DT.Time().In(loc).Format(layout)))
+	return 1
+}
+
+func dateTimeToISOStringMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+
+	L.Push(lua.LString(dateTime.DT.Time().UTC().Format("2006-01-02T15:04:05.000Z07:00")))
+	return 1
+}
+
+// dateTimeToTableMethod converts to os.date("*t") table, in local time by
+// default like os.date
+func dateTimeToTableMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+	loc, err := toLocation(L.OptString(2, "Local"))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	t := dateTime.DT.Time().In(loc)
+	tb := L.NewTable()
+	tb.RawSetString("year", lua.LNumber(t.Year()))
+	tb.RawSetString("month", lua.LNumber(t.Month()))
+	tb.RawSetString("day", lua.LNumber(t.Day()))
+	tb.RawSetString("hour", lua.LNumber(t.Hour()))
+	tb.RawSetString("min", lua.LNumber(t.Minute()))
+	tb.RawSetString("sec", lua.LNumber(t.Second()))
+	tb.RawSetString("wday", lua.LNumber(t.Weekday()+1))
+	tb.RawSetString("yday", lua.LNumber(t.YearDay()))
+	tb.RawSetString("isdst", lua.LBool(t.IsDST()))
+	L.Push(tb)
+	return 1
+}
+
+// dateTimeTruncateMethod truncates to the start of unit in UTC, weeks start
+// on Sunday
+func dateTimeTruncateMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+	unit := L.CheckString(2)
+
+	t := dateTime.DT.Time().UTC()
+	switch unit {
+	case "millisecond":
+	case "second":
+		t = t.Truncate(time.Second)
+	case "minute":
+		t = t.Truncate(time.Minute)
+	case "hour":
+		t = t.Truncate(time.Hour)
+	case "day":
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "week":
+		t = time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, time.UTC)
+	case "month":
+		t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case "year":
+		t = time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		L.ArgError(2, "invalid unit")
+		return 0
+	}
+
+	L.Push(LDateTime(L, primitive.NewDateTimeFromTime(t)))
+	return 1
+}
+
+func dateTimeUnixMillisMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+
+	L.Push(lua.LNumber(dateTime.DT))
+	return 1
+}
+
+func dateTimeLtMethod(L *lua.LState) int {
+	dateTime1 := checkDateTime(L, 1)
+	dateTime2 := checkDateTime(L, 2)
+
+	L.Push(lua.LBool(dateTime1.DT < dateTime2.DT))
+	return 1
+}
+
+func dateTimeLeMethod(L *lua.LState) int {
+	dateTime1 := checkDateTime(L, 1)
+	dateTime2 := checkDateTime(L, 2)
+
+	L.Push(lua.LBool(dateTime1.DT <= dateTime2.DT))
+	return 1
+}
+
+// dateTimeSubMethod returns the milliseconds between two DateTime, or the
+// DateTime some milliseconds earlier
+func dateTimeSubMethod(L *lua.LState) int {
+	dateTime := checkDateTime(L, 1)
+
+	if ms, ok := L.Get(2).(lua.LNumber); ok {
+		L.Push(LDateTime(L, dateTime.DT-primitive.DateTime(ms)))
+		return 1
+	}
+	dateTime2 := checkDateTime(L, 2)
+
+	L.Push(lua.LNumber(dateTime.DT - dateTime2.DT))
+	return 1
+}
@@ -0,0 +1,61 @@
+package bsonutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestDateTimeMethods(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local dt = bson.DateTime("2021-05-06T05:01:31.038Z")
+		local dt2 = dt:addDuration("1h30m")
+		local tb = dt:toTable("UTC")
+		return dt:unixMillis(), dt:toISOString(), dt:format("2006-01-02 15:04", "Asia/Shanghai"),
+		  dt2 - dt, dt < dt2, dt2 <= dt, (dt - 1038):toISOString(),
+		  dt:truncate("day"):toISOString(), dt:truncate("week"):toISOString(),
+		  dt:truncate("month"):toISOString(), tb.year, tb.month, tb.day, tb.hour, tb.wday,
+		  bson.DateTime("2021-05-06"):toISOString(), bson.DateTime.now() > dt,
+		  bson.DateTime(os.date("*t", 1620277291)):unixMillis()
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(18, L.GetTop())
+	assert.Equal(lua.LNumber(1620277291038), L.Get(1))
+	assert.Equal("2021-05-06T05:01:31.038Z", L.ToString(2))
+	assert.Equal("2021-05-06 13:01", L.ToString(3))
+	assert.Equal(lua.LNumber(90*time.Minute/time.Millisecond), L.Get(4))
+	assert.Equal(lua.LTrue, L.Get(5))
+	assert.Equal(lua.LFalse, L.Get(6))
+	assert.Equal("2021-05-06T05:01:30.000Z", L.ToString(7))
+	assert.Equal("2021-05-06T00:00:00.000Z", L.ToString(8))
+	assert.Equal("2021-05-02T00:00:00.000Z", L.ToString(9))
+	assert.Equal("2021-05-01T00:00:00.000Z", L.ToString(10))
+	assert.Equal(lua.LNumber(2021), L.Get(11))
+	assert.Equal(lua.LNumber(5), L.Get(12))
+	assert.Equal(lua.LNumber(6), L.Get(13))
+	assert.Equal(lua.LNumber(5), L.Get(14))
+	assert.Equal(lua.LNumber(5), L.Get(15))
+	assert.Equal("2021-05-06T00:00:00.000Z", L.ToString(16))
+	assert.Equal(lua.LTrue, L.Get(17))
+	assert.Equal(lua.LNumber(1620277291000), L.Get(18))
+
+	for _, script := range []string{
+		`return require('bson').DateTime({year = 2021})`,
+		`return require('bson').DateTime(0):truncate("decade")`,
+		`return require('bson').DateTime(0):format("2006", "Nowhere/City")`,
+	} {
+		assert.Error(L.DoString(script), script)
+	}
+}
@@ -0,0 +1,287 @@
+package bsonutil
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bson types
+const (
+	DECIMAL128_TYPENAME = "bson{decimal128}"
+)
+
+// decimal128Digits is the precision of decimal128 significand
+const decimal128Digits = 34
+
+var (
+	errDecimal128NotFinite = errors.New("decimal128 NaN or Infinity")
+	errDecimal128Overflow  = errors.New("decimal128 overflow")
+	errDecimal128DivZero   = errors.New("decimal128 division by zero")
+)
+
+// Decimal128 mongo
+type Decimal128 struct {
+	D primitive.Decimal128
+}
+
+var decimal128Methods = map[string]lua.LGFunction{
+	"toNumber": decimal128ToNumberMethod,
+	"toString": decimal128ToStringMethod,
+}
+
+// NewDecimal128 new Decimal128 for glua, from string or number
+func NewDecimal128(L *lua.LState) int {
+	d, err := toDecimal128(L.Get(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+		return 0
+	}
+
+	L.Push(LDecimal128(L, d))
+	return 1
+}
+
+// LDecimal128 creates Decimal128 value for glua
+func LDecimal128(L *lua.LState, d primitive.Decimal128) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Decimal128{D: d}
+	L.SetMetatable(ud, L.GetTypeMetatable(DECIMAL128_TYPENAME))
+	return ud
+}
+
+// toDecimal128 converts decimal string, lua number or bson number to
+// decimal128, lua numbers are converted by their shortest representation
+func toDecimal128(lv lua.LValue) (primitive.Decimal128, error) {
+	switch v := lv.(type) {
+	case lua.LString:
+		return primitive.ParseDecimal128(string(v))
+	case lua.LNumber:
+		return primitive.ParseDecimal128(strconv.FormatFloat(float64(v), 'g', -1, 64))
+	case *lua.LUserData:
+		switch n := v.Value.(type) {
+		case *Decimal128:
+			return n.D, nil
+		}
+		if n, ok := toBSONNumber(v); ok {
+			if n.kind == kindDouble {
+				return primitive.ParseDecimal128(strconv.FormatFloat(n.f, 'g', -1, 64))
+			}
+			return primitive.ParseDecimal128(strconv.FormatInt(n.i, 10))
+		}
+	}
+	return primitive.Decimal128{}, fmt.Errorf("decimal128 expected, got %s", lv.Type())
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// newDecimal128 rounds the significand half to even to fit decimal128
+func newDecimal128(bi *big.Int, exp int) (primitive.Decimal128, error) {
+	abs := new(big.Int).Abs(bi)
+	drop := len(abs.String()) - decimal128Digits
+	if d := primitive.MinDecimal128Exp - exp; d > drop {
+		drop = d
+	}
+	if drop > 0 {
+		divisor := pow10(drop)
+		r := new(big.Int)
+		abs.QuoRem(abs, divisor, r)
+		switch r.Lsh(r, 1).Cmp(divisor) {
+		case 1:
+			abs.Add(abs, big.NewInt(1))
+		case 0:
+			if abs.Bit(0) == 1 {
+				abs.Add(abs, big.NewInt(1))
+			}
+		}
+		exp += drop
+		if len(abs.String()) > decimal128Digits {
+			abs.Quo(abs, big.NewInt(10))
+			exp++
+		}
+	}
+	if bi.Sign() < 0 {
+		abs.Neg(abs)
+	}
+	d, ok := primitive.ParseDecimal128FromBigInt(abs, exp)
+	if !ok {
+		return primitive.Decimal128{}, errDecimal128Overflow
+	}
+	return d, nil
+}
+
+// decimalOperand is significand * 10^exp
+type decimalOperand struct {
+	bi  *big.Int
+	exp int
+}
+
+func toDecimalOperand(lv lua.LValue) (decimalOperand, error) {
+	d, err := toDecimal128(lv)
+	if err != nil {
+		return decimalOperand{}, err
+	}
+	bi, exp, err := d.BigInt()
+	if err != nil {
+		return decimalOperand{}, errDecimal128NotFinite
+	}
+	return decimalOperand{bi: bi, exp: exp}, nil
+}
+
+// align returns the significands of a and b with the same exponent
+func align(a, b decimalOperand) (*big.Int, *big.Int, int) {
+	x, y := new(big.Int).Set(a.bi), new(big.Int).Set(b.bi)
+	if a.exp > b.exp {
+		x.Mul(x, pow10(a.exp-b.exp))
+		return x, y, b.exp
+	}
+	y.Mul(y, pow10(b.exp-a.exp))
+	return x, y, a.exp
+}
+
+func decimalAdd(a, b decimalOperand) (primitive.Decimal128, error) {
+	x, y, exp := align(a, b)
+	return newDecimal128(x.Add(x, y), exp)
+}
+
+func decimalSub(a, b decimalOperand) (primitive.Decimal128, error) {
+	x, y, exp := align(a, b)
+	return newDecimal128(x.Sub(x, y), exp)
+}
+
+func decimalMul(a, b decimalOperand) (primitive.Decimal128, error) {
+	return newDecimal128(new(big.Int).Mul(a.bi, b.bi), a.exp+b.exp)
+}
+
+// decimalDiv keeps exact quotients short, e.g. 1/4 is 0.25, inexact
+// quotients are rounded to the full precision
+func decimalDiv(a, b decimalOperand) (primitive.Decimal128, error) {
+	if b.bi.Sign() == 0 {
+		return primitive.Decimal128{}, errDecimal128DivZero
+	}
+	// enough digits for rounding, the last one marks a nonzero remainder
+	scale := decimal128Digits + 2 + len(b.bi.String()) - len(a.bi.String())
+	if scale < 0 {
+		scale = 0
+	}
+	num := new(big.Int).Mul(a.bi, pow10(scale))
+	q, r := new(big.Int).QuoRem(num, b.bi, new(big.Int))
+	exp := a.exp - b.exp - scale
+	if r.Sign() != 0 {
+		q.Mul(q, big.NewInt(10))
+		if num.Sign()*b.bi.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+		return newDecimal128(q, exp-1)
+	}
+	ten, m := big.NewInt(10), new(big.Int)
+	for exp < a.exp-b.exp && q.Sign() != 0 {
+		if _, m = new(big.Int).QuoRem(q, ten, m); m.Sign() != 0 {
+			break
+		}
+		q.Quo(q, ten)
+		exp++
+	}
+	return newDecimal128(q, exp)
+}
+
+func decimalCmp(a, b decimalOperand) int {
+	x, y, _ := align(a, b)
+	return x.Cmp(y)
+}
+
+func checkDecimalOperands(L *lua.LState) (decimalOperand, decimalOperand) {
+	a, err := toDecimalOperand(L.Get(1))
+	if err != nil {
+		L.RaiseError(err.Error())
+	}
+	b, err := toDecimalOperand(L.Get(2))
+	if err != nil {
+		L.RaiseError(err.Error())
+	}
+	return a, b
+}
+
+func decimal128Arith(op func(a, b decimalOperand) (primitive.Decimal128, error)) lua.LGFunction {
+	return func(L *lua.LState) int {
+		d, err := op(checkDecimalOperands(L))
+		if err != nil {
+			L.RaiseError(err.Error())
+			return 0
+		}
+		L.Push(LDecimal128(L, d))
+		return 1
+	}
+}
+
+func decimal128EqMethod(L *lua.LState) int {
+	d1, err1 := toDecimal128(L.Get(1))
+	d2, err2 := toDecimal128(L.Get(2))
+	if err1 != nil || err2 != nil {
+		L.Push(lua.LFalse)
+		return 1
+	}
+	if d1.IsNaN() || d2.IsNaN() {
+		L.Push(lua.LFalse)
+		return 1
+	}
+	if d1.IsInf() != 0 || d2.IsInf() != 0 {
+		L.Push(lua.LBool(d1.IsInf() == d2.IsInf()))
+		return 1
+	}
+	a, b := checkDecimalOperands(L)
+	L.Push(lua.LBool(decimalCmp(a, b) == 0))
+	return 1
+}
+
+func decimal128LtMethod(L *lua.LState) int {
+	L.Push(lua.LBool(decimalCmp(checkDecimalOperands(L)) < 0))
+	return 1
+}
+
+func decimal128LeMethod(L *lua.LState) int {
+	L.Push(lua.LBool(decimalCmp(checkDecimalOperands(L)) <= 0))
+	return 1
+}
+
+func checkDecimal128(L *lua.LState) *Decimal128 {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*Decimal128); ok {
+		return v
+	}
+	L.ArgError(1, "bson decimal128 expected")
+	return nil
+}
+
+func decimal128ToStringMethod(L *lua.LState) int {
+	d := checkDecimal128(L)
+
+	L.Push(lua.LString(d.D.String()))
+	return 1
+}
+
+// decimal128ToNumberMethod converts to lua number, may lose precision
+func decimal128ToNumberMethod(L *lua.LState) int {
+	d := checkDecimal128(L)
+
+	// ParseFloat accepts NaN and Infinity too
+	f, _ := strconv.ParseFloat(d.D.String(), 64)
+	L.Push(lua.LNumber(f))
+	return 1
+}
+
+func decimal128ToStringMetaMethod(L *lua.LState) int {
+	d := checkDecimal128(L)
+
+	L.Push(lua.LString(fmt.Sprintf("Decimal128(%s)", d.D.String())))
+	return 1
+}
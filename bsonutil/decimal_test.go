@@ -0,0 +1,74 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDecimal128(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local D = bson.Decimal128
+		local a = D("0.1")
+		return (a + D("0.2")):toString(), (D("1.10") - 1):toString(), (a * D("3.5")):toString(),
+		  (D(1) / D(4)):toString(), (D(1) / D(3)):toString(), (D(2) / D(3)):toString(),
+		  a + D("0.2") == D("0.30"), a < D("0.11"), D("1E+2") <= D(100), tostring(a),
+		  D("12.5"):toNumber(), D(bson.Int64("9007199254740993")):toString(),
+		  (bson.Int64(1) + D("0.5")):toString(), (bson.Int32(3) * D("0.5")):toString(),
+		  (bson.Double(1) - D("0.25")):toString(), (D("0.5") + bson.Int64(1)):toString()
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(16, L.GetTop())
+	assert.Equal("0.3", L.ToString(1))
+	assert.Equal("0.10", L.ToString(2))
+	assert.Equal("0.35", L.ToString(3))
+	assert.Equal("0.25", L.ToString(4))
+	assert.Equal("0.3333333333333333333333333333333333", L.ToString(5))
+	assert.Equal("0.6666666666666666666666666666666667", L.ToString(6))
+	assert.Equal(lua.LTrue, L.Get(7))
+	assert.Equal(lua.LTrue, L.Get(8))
+	assert.Equal(lua.LTrue, L.Get(9))
+	assert.Equal("Decimal128(0.1)", L.ToString(10))
+	assert.Equal(lua.LNumber(12.5), L.Get(11))
+	assert.Equal("9007199254740993", L.ToString(12))
+	// bson numbers hand off to decimal arithmetic
+	assert.Equal("1.5", L.ToString(13))
+	assert.Equal("1.5", L.ToString(14))
+	assert.Equal("0.75", L.ToString(15))
+	assert.Equal("1.5", L.ToString(16))
+
+	for _, script := range []string{
+		`return require('bson').Decimal128("x")`,
+		`return require('bson').Decimal128(1) / 0`,
+		`return require('bson').Decimal128("NaN") + 1`,
+		`return require('bson').Decimal128("9.999999999999999999999999999999999E+6144") * 10`,
+	} {
+		assert.Error(L.DoString(script), script)
+	}
+}
+
+func TestDecimal128Value(t *testing.T) {
+	assert := assert.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	d, err := primitive.ParseDecimal128("-12.345")
+	assert.NoError(err)
+	lv := ToLuaValue(L, d)
+	assert.Equal("Decimal128(-12.345)", L.ToStringMeta(lv).String())
+	assert.Equal(d, Value(L, lv))
+}
@@ -0,0 +1,174 @@
+package bsonutil
+
+import (
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// bson types
+const (
+	DOC_TYPENAME = "bson{doc}"
+)
+
+// Doc ordered document, keys keep the insertion order
+type Doc struct {
+	Keys   []string
+	Values map[string]lua.LValue
+}
+
+// NewDoc new Doc for glua, from an array of {key, value} pairs or extended
+// json string:
+//
+//	mongo.Doc({{"a", 1}, {"b", -1}})
+//	mongo.Doc('{"a": 1, "b": -1}')
+func NewDoc(L *lua.LState) int {
+	doc := &Doc{Values: map[string]lua.LValue{}}
+
+	switch lv := L.Get(1); lv.Type() {
+	case lua.LTNil:
+	case lua.LTString:
+		val, err := UnmarshalBSON(lua.LVAsString(lv))
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		d, ok := val.(bson.D)
+		if !ok {
+			L.ArgError(1, "document expected")
+			return 0
+		}
+		opts := &DecodeOptions{Ordered: true}
+		for _, e := range d {
+			doc.set(e.Key, ToLuaValueWith(L, e.Value, opts))
+		}
+	case lua.LTTable:
+		tb := lv.(*lua.LTable)
+		for i := 1; i <= tb.Len(); i++ {
+			pair, ok := tb.RawGetInt(i).(*lua.LTable)
+			if !ok {
+				L.ArgError(1, "{key, value} pairs expected")
+				return 0
+			}
+			key, ok := pair.RawGetInt(1).(lua.LString)
+			if !ok {
+				L.ArgError(1, "string key expected")
+				return 0
+			}
+			doc.set(string(key), pair.RawGetInt(2))
+		}
+	default:
+		L.ArgError(1, "pairs or string expected")
+		return 0
+	}
+
+	L.Push(LDoc(L, doc))
+	return 1
+}
+
+// LDoc creates Doc value for glua
+func LDoc(L *lua.LState, doc *Doc) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = doc
+	L.SetMetatable(ud, L.GetTypeMetatable(DOC_TYPENAME))
+	return ud
+}
+
+func checkDoc(L *lua.LState, idx int) *Doc {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Doc); ok {
+		return v
+	}
+	L.ArgError(idx, "bson doc expected")
+	return nil
+}
+
+// set sets value of key, nil removes the key
+func (doc *Doc) set(key string, v lua.LValue) {
+	_, exists := doc.Values[key]
+	if v == lua.LNil {
+		if exists {
+			delete(doc.Values, key)
+			for i, k := range doc.Keys {
+				if k == key {
+					doc.Keys = append(doc.Keys[:i], doc.Keys[i+1:]...)
+					break
+				}
+			}
+		}
+		return
+	}
+	if !exists {
+		doc.Keys = append(doc.Keys, key)
+	}
+	doc.Values[key] = v
+}
+
+// D converts doc to bson.D with converter for the values
+func (doc *Doc) D(value func(lua.LValue) interface{}) bson.D {
+	d := make(bson.D, 0, len(doc.Keys))
+	for _, k := range doc.Keys {
+		d = append(d, bson.E{Key: k, Value: value(doc.Values[k])})
+	}
+	return d
+}
+
+func docIndexMethod(L *lua.LState) int {
+	doc := checkDoc(L, 1)
+	key := L.CheckString(2)
+
+	if v, ok := doc.Values[key]; ok {
+		L.Push(v)
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 1
+}
+
+func docNewIndexMethod(L *lua.LState) int {
+	doc := checkDoc(L, 1)
+	key := L.CheckString(2)
+
+	doc.set(key, L.Get(3))
+	return 0
+}
+
+func docLenMethod(L *lua.LState) int {
+	doc := checkDoc(L, 1)
+
+	L.Push(lua.LNumber(len(doc.Keys)))
+	return 1
+}
+
+// docCallMethod returns iterator in key order, as lua 5.1 has no __pairs:
+// for k, v in doc() do ... end
+func docCallMethod(L *lua.LState) int {
+	doc := checkDoc(L, 1)
+
+	i := 0
+	L.Push(L.NewFunction(func(L *lua.LState) int {
+		if i >= len(doc.Keys) {
+			L.Push(lua.LNil)
+			return 1
+		}
+		key := doc.Keys[i]
+		i++
+		L.Push(lua.LString(key))
+		L.Push(doc.Values[key])
+		return 2
+	}))
+	return 1
+}
+
+func docToStringMethod(L *lua.LState) int {
+	doc := checkDoc(L, 1)
+
+	data, err := bson.MarshalExtJSON(doc.D(func(v lua.LValue) interface{} {
+		return Value(L, v)
+	}), false, false)
+	if err != nil {
+		L.Push(lua.LString("Doc(?)"))
+		return 1
+	}
+	L.Push(lua.LString("Doc(" + string(data) + ")"))
+	return 1
+}
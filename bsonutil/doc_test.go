@@ -0,0 +1,85 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDoc(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local doc = bson.Doc({{"z", 1}, {"y", -1}})
+		doc.x = 1
+		doc.a = {b = 2}
+		doc.y = nil
+		doc.z = 2
+		local keys = {}
+		for k, v in doc() do
+		  table.insert(keys, k .. '=' .. (type(v) == 'table' and 'table' or tostring(v)))
+		end
+		local doc2 = bson.Doc('{"b": 1, "a": {"d": 1, "c": 2}}')
+		return doc, #doc, table.concat(keys, ','), doc2, doc2.a.c, tostring(bson.Doc({{"a", 1}}))
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(6, L.GetTop())
+	assert.Equal(bson.D{
+		{Key: "z", Value: 2},
+		{Key: "x", Value: 1},
+		{Key: "a", Value: map[string]interface{}{"b": 2}},
+	}, GetValue(L, 1))
+	assert.Equal(lua.LNumber(3), L.Get(2))
+	assert.Equal("z=2,x=1,a=table", L.ToString(3))
+	assert.Equal(bson.D{
+		{Key: "b", Value: 1},
+		{Key: "a", Value: bson.D{{Key: "d", Value: 1}, {Key: "c", Value: 2}}},
+	}, GetValue(L, 4))
+	assert.Equal(lua.LNumber(2), L.Get(5))
+	assert.Equal(`Doc({"a":1})`, L.ToString(6))
+
+	script = `
+		local bson = require 'bson'
+		return bson.Doc({1, 2})
+	`
+	require.Error(L.DoString(script))
+}
+
+func TestToLuaValueOrdered(t *testing.T) {
+	assert := assert.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	d := bson.D{
+		{Key: "b", Value: int32(1)},
+		{Key: "a", Value: bson.D{{Key: "d", Value: "x"}}},
+		{Key: "arr", Value: bson.A{bson.D{{Key: "c", Value: true}}}},
+	}
+	lv := ToLuaValueWith(L, d, &DecodeOptions{Ordered: true})
+	assert.Equal(lua.LTUserData, lv.Type())
+	assert.Equal(bson.D{
+		{Key: "b", Value: 1},
+		{Key: "a", Value: bson.D{{Key: "d", Value: "x"}}},
+		{Key: "arr", Value: []interface{}{bson.D{{Key: "c", Value: true}}}},
+	}, Value(L, lv))
+
+	lv = ToLuaValue(L, d)
+	assert.Equal(lua.LTTable, lv.Type())
+	assert.Equal(map[string]interface{}{
+		"b":   1,
+		"a":   map[string]interface{}{"d": "x"},
+		"arr": []interface{}{map[string]interface{}{"c": true}},
+	}, Value(L, lv))
+}
@@ -0,0 +1,361 @@
+package bsonutil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bson types
+const (
+	INT32_TYPENAME  = "bson{int32}"
+	INT64_TYPENAME  = "bson{int64}"
+	DOUBLE_TYPENAME = "bson{double}"
+)
+
+// maxSafeInteger is the largest integer a lua number holds exactly
+const maxSafeInteger = 1 << 53
+
+// Int32 mongo
+type Int32 struct {
+	V int32
+}
+
+// Int64 mongo
+type Int64 struct {
+	V int64
+}
+
+// Double mongo
+type Double struct {
+	V float64
+}
+
+var numberMethods = map[string]lua.LGFunction{
+	"toNumber": numberToNumberMethod,
+}
+
+// numberKind orders the promotion of the operands, lua numbers take the kind
+// of the other operand if integral
+type numberKind int
+
+const (
+	kindLua numberKind = iota
+	kindInt32
+	kindInt64
+	kindDouble
+)
+
+type number struct {
+	kind numberKind
+	i    int64
+	f    float64
+}
+
+func (n number) integral() bool {
+	switch n.kind {
+	case kindInt32, kindInt64:
+		return true
+	case kindLua:
+		return n.f == math.Trunc(n.f) && math.Abs(n.f) <= maxSafeInteger
+	}
+	return false
+}
+
+func toBSONNumber(lv lua.LValue) (number, bool) {
+	switch v := lv.(type) {
+	case lua.LNumber:
+		f := float64(v)
+		return number{kind: kindLua, i: int64(f), f: f}, true
+	case *lua.LUserData:
+		switch n := v.Value.(type) {
+		case *Int32:
+			return number{kind: kindInt32, i: int64(n.V), f: float64(n.V)}, true
+		case *Int64:
+			return number{kind: kindInt64, i: n.V, f: float64(n.V)}, true
+		case *Double:
+			return number{kind: kindDouble, i: int64(n.V), f: n.V}, true
+		}
+	}
+	return number{}, false
+}
+
+func checkBSONNumber(L *lua.LState, idx int) number {
+	n, ok := toBSONNumber(L.Get(idx))
+	if !ok {
+		L.ArgError(idx, "number expected")
+	}
+	return n
+}
+
+// NewInt32 new Int32 for glua
+func NewInt32(L *lua.LState) int {
+	n := checkBSONNumber(L, 1)
+	if !n.integral() || n.i < math.MinInt32 || n.i > math.MaxInt32 {
+		L.ArgError(1, "int32 expected")
+		return 0
+	}
+
+	L.Push(LInt32(L, int32(n.i)))
+	return 1
+}
+
+// NewInt64 new Int64 for glua, from number or decimal string
+func NewInt64(L *lua.LState) int {
+	if L.Get(1).Type() == lua.LTString {
+		i, err := strconv.ParseInt(L.ToString(1), 10, 64)
+		if err != nil {
+			L.ArgError(1, err.Error())
+			return 0
+		}
+		L.Push(LInt64(L, i))
+		return 1
+	}
+
+	n := checkBSONNumber(L, 1)
+	if !n.integral() {
+		L.ArgError(1, "int64 expected")
+		return 0
+	}
+
+	L.Push(LInt64(L, n.i))
+	return 1
+}
+
+// NewDouble new Double for glua
+func NewDouble(L *lua.LState) int {
+	n := checkBSONNumber(L, 1)
+
+	L.Push(LDouble(L, n.f))
+	return 1
+}
+
+// LInt32 creates Int32 value for glua
+func LInt32(L *lua.LState, i int32) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Int32{V: i}
+	L.SetMetatable(ud, L.GetTypeMetatable(INT32_TYPENAME))
+	return ud
+}
+
+// LInt64 creates Int64 value for glua
+func LInt64(L *lua.LState, i int64) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Int64{V: i}
+	L.SetMetatable(ud, L.GetTypeMetatable(INT64_TYPENAME))
+	return ud
+}
+
+// LDouble creates Double value for glua
+func LDouble(L *lua.LState, f float64) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Double{V: f}
+	L.SetMetatable(ud, L.GetTypeMetatable(DOUBLE_TYPENAME))
+	return ud
+}
+
+// pushNumber pushes the result of kind, integer results are range checked
+func pushNumber(L *lua.LState, kind numberKind, i int64, f float64) int {
+	switch kind {
+	case kindInt32:
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			L.RaiseError("int32 overflow")
+			return 0
+		}
+		L.Push(LInt32(L, int32(i)))
+	case kindInt64:
+		L.Push(LInt64(L, i))
+	default:
+		L.Push(LDouble(L, f))
+	}
+	return 1
+}
+
+// resultKind returns the kind of binary operation result
+func resultKind(a, b number) numberKind {
+	kind := a.kind
+	if b.kind > kind {
+		kind = b.kind
+	}
+	if (a.kind == kindLua && !a.integral()) || (b.kind == kindLua && !b.integral()) {
+		kind = kindDouble
+	}
+	return kind
+}
+
+func checkOperands(L *lua.LState) (number, number, numberKind) {
+	a, ok1 := toBSONNumber(L.Get(1))
+	b, ok2 := toBSONNumber(L.Get(2))
+	if !ok1 || !ok2 {
+		L.RaiseError("attempt to perform arithmetic on %s and %s", L.Get(1).Type(), L.Get(2).Type())
+	}
+	return a, b, resultKind(a, b)
+}
+
+func numberAddMethod(L *lua.LState) int {
+	a, b, kind := checkOperands(L)
+
+	i := a.i + b.i
+	if kind == kindInt64 && ((b.i > 0 && i < a.i) || (b.i < 0 && i > a.i)) {
+		L.RaiseError("int64 overflow")
+	}
+	return pushNumber(L, kind, i, a.f+b.f)
+}
+
+func numberSubMethod(L *lua.LState) int {
+	a, b, kind := checkOperands(L)
+
+	i := a.i - b.i
+	if kind == kindInt64 && ((b.i < 0 && i < a.i) || (b.i > 0 && i > a.i)) {
+		L.RaiseError("int64 overflow")
+	}
+	return pushNumber(L, kind, i, a.f-b.f)
+}
+
+func numberMulMethod(L *lua.LState) int {
+	a, b, kind := checkOperands(L)
+
+	i := a.i * b.i
+	if kind == kindInt64 && a.i != 0 && (i/a.i != b.i || (a.i == -1 && b.i == math.MinInt64)) {
+		L.RaiseError("int64 overflow")
+	}
+	return pushNumber(L, kind, i, a.f*b.f)
+}
+
+// numberDivMethod always divides as doubles like lua and the mongo shell,
+// e.g. Int32(1) / 2 is Double(0.5)
+func numberDivMethod(L *lua.LState) int {
+	a, b, _ := checkOperands(L)
+
+	return pushNumber(L, kindDouble, 0, a.f/b.f)
+}
+
+// numberModMethod follows lua, the result has the sign of the divisor
+func numberModMethod(L *lua.LState) int {
+	a, b, kind := checkOperands(L)
+
+	if kind == kindDouble {
+		return pushNumber(L, kind, 0, a.f-math.Floor(a.f/b.f)*b.f)
+	}
+	if b.i == 0 {
+		L.RaiseError("integer modulo by zero")
+	}
+	r := a.i % b.i
+	if r != 0 && (r^b.i) < 0 {
+		r += b.i
+	}
+	return pushNumber(L, kind, r, 0)
+}
+
+func numberUnmMethod(L *lua.LState) int {
+	a, _ := toBSONNumber(L.Get(1))
+
+	return pushNumber(L, a.kind, -a.i, -a.f)
+}
+
+// compareNumbers compares integers exactly, doubles otherwise
+func compareNumbers(L *lua.LState) int {
+	a, b, kind := checkOperands(L)
+
+	if kind == kindDouble {
+		switch {
+		case a.f < b.f:
+			return -1
+		case a.f > b.f:
+			return 1
+		}
+		return 0
+	}
+	switch {
+	case a.i < b.i:
+		return -1
+	case a.i > b.i:
+		return 1
+	}
+	return 0
+}
+
+func numberEqMethod(L *lua.LState) int {
+	L.Push(lua.LBool(compareNumbers(L) == 0))
+	return 1
+}
+
+func numberLtMethod(L *lua.LState) int {
+	L.Push(lua.LBool(compareNumbers(L) < 0))
+	return 1
+}
+
+func numberLeMethod(L *lua.LState) int {
+	L.Push(lua.LBool(compareNumbers(L) <= 0))
+	return 1
+}
+
+func numberToStringMethod(L *lua.LState) int {
+	switch n := L.CheckUserData(1).Value.(type) {
+	case *Int32:
+		L.Push(lua.LString(fmt.Sprintf("Int32(%d)", n.V)))
+	case *Int64:
+		L.Push(lua.LString(fmt.Sprintf("Int64(%d)", n.V)))
+	case *Double:
+		L.Push(lua.LString(fmt.Sprintf("Double(%s)", strconv.FormatFloat(n.V, 'g', -1, 64))))
+	default:
+		L.ArgError(1, "bson number expected")
+		return 0
+	}
+	return 1
+}
+
+// numberToNumberMethod converts to lua number, int64 may lose precision
+func numberToNumberMethod(L *lua.LState) int {
+	n := checkBSONNumber(L, 1)
+
+	L.Push(lua.LNumber(n.f))
+	return 1
+}
+
+// decimalFallback hands the arithmetic over to decimal128 for decimal128 operands
+func decimalFallback(fn lua.LGFunction, op func(a, b decimalOperand) (primitive.Decimal128, error)) lua.LGFunction {
+	decimalFn := decimal128Arith(op)
+	return func(L *lua.LState) int {
+		for _, lv := range []lua.LValue{L.Get(1), L.Get(2)} {
+			if ud, ok := lv.(*lua.LUserData); ok {
+				if _, ok := ud.Value.(*Decimal128); ok {
+					return decimalFn(L)
+				}
+			}
+		}
+		return fn(L)
+	}
+}
+
+// registerNumberTypes shares the metamethods between number types, so that
+// they can be compared with each other
+func registerNumberTypes(L *lua.LState) {
+	methods := L.SetFuncs(L.NewTable(), numberMethods)
+	metamethods := map[string]lua.LGFunction{
+		"__add":      decimalFallback(numberAddMethod, decimalAdd),
+		"__sub":      decimalFallback(numberSubMethod, decimalSub),
+		"__mul":      decimalFallback(numberMulMethod, decimalMul),
+		"__div":      decimalFallback(numberDivMethod, decimalDiv),
+		"__mod":      numberModMethod,
+		"__unm":      numberUnmMethod,
+		"__eq":       numberEqMethod,
+		"__lt":       numberLtMethod,
+		"__le":       numberLeMethod,
+		"__tostring": numberToStringMethod,
+	}
+	fns := make(map[string]*lua.LFunction, len(metamethods))
+	for name, fn := range metamethods {
+		fns[name] = L.NewFunction(fn)
+	}
+	for _, typename := range []string{INT32_TYPENAME, INT64_TYPENAME, DOUBLE_TYPENAME} {
+		mt := L.NewTypeMetatable(typename)
+		L.SetField(mt, "__index", methods)
+		for name, fn := range fns {
+			L.SetField(mt, name, fn)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bsonutil
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestNumber(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local a = bson.Int64("9007199254740993")
+		local b = bson.Int32(7)
+		return a + 1, b * 2, b / 2, -b % 3, b + 0.5, a == bson.Int64("9007199254740993"),
+		  b < bson.Double(7.5), b <= bson.Int64(6), tostring(a), tostring(bson.Double(1.5)),
+		  b:toNumber(), bson.Int32(1), bson.Int32(1) / 2, bson.Int32(1) / 0
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(14, L.GetTop())
+	assert.Equal(int64(9007199254740994), GetValue(L, 1))
+	assert.Equal(int32(14), GetValue(L, 2))
+	assert.Equal(3.5, GetValue(L, 3))
+	assert.Equal(int32(2), GetValue(L, 4))
+	assert.Equal(7.5, GetValue(L, 5))
+	assert.Equal(lua.LTrue, L.Get(6))
+	assert.Equal(lua.LTrue, L.Get(7))
+	assert.Equal(lua.LFalse, L.Get(8))
+	assert.Equal("Int64(9007199254740993)", L.ToString(9))
+	assert.Equal("Double(1.5)", L.ToString(10))
+	assert.Equal(lua.LNumber(7), L.Get(11))
+	assert.Equal(int32(1), GetValue(L, 12))
+	// division is never truncated
+	assert.Equal(0.5, GetValue(L, 13))
+	assert.Equal(math.Inf(1), GetValue(L, 14))
+
+	for _, script := range []string{
+		`return require('bson').Int32(2147483647) + 1`,
+		`return require('bson').Int64("9223372036854775807") + 1`,
+		`return require('bson').Int32(1.5)`,
+		`return require('bson').Int64("x")`,
+	} {
+		assert.Error(L.DoString(script), script)
+	}
+}
+
+func TestToLuaValueInt64(t *testing.T) {
+	assert := assert.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	opts := &DecodeOptions{Int64: true}
+	assert.Equal(lua.LNumber(1), ToLuaValueWith(L, int64(1), opts))
+	lv := ToLuaValueWith(L, int64(math.MaxInt64), opts)
+	assert.Equal(int64(math.MaxInt64), Value(L, lv))
+	assert.Equal(lua.LTNumber, ToLuaValue(L, int64(math.MaxInt64)).Type())
+}
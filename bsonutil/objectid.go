@@ -1,6 +1,10 @@
 package bsonutil
 
 import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
 	lua "github.com/yuin/gopher-lua"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -15,7 +19,15 @@ type ObjectID struct {
 	OID primitive.ObjectID
 }
 
-var objectIDMethods = map[string]lua.LGFunction{}
+var objectIDMethods = map[string]lua.LGFunction{
+	"getTimestamp": objectIDGetTimestampMethod,
+	"hex":          objectIDHexMethod,
+}
+
+var objectIDFuncs = map[string]lua.LGFunction{
+	"fromTime": objectIDFromTimeFunc,
+	"isValid":  objectIDIsValidFunc,
+}
 
 // NewObjectID new ObjectID for glua
 func NewObjectID(L *lua.LState) int {
@@ -58,7 +70,7 @@ func checkObjectID(L *lua.LState, idx int) *ObjectID {
 	if v, ok := ud.Value.(*ObjectID); ok {
 		return v
 	}
-	L.ArgError(1, "bson objectid expected")
+	L.ArgError(idx, "bson objectid expected")
 	return nil
 }
 
@@ -76,3 +88,62 @@ func objectIDEqMethod(L *lua.LState) int {
 	L.Push(lua.LBool(objectID1.OID == objectID2.OID))
 	return 1
 }
+
+func objectIDLtMethod(L *lua.LState) int {
+	objectID1 := checkObjectID(L, 1)
+	objectID2 := checkObjectID(L, 2)
+
+	L.Push(lua.LBool(bytes.Compare(objectID1.OID[:], objectID2.OID[:]) < 0))
+	return 1
+}
+
+func objectIDLeMethod(L *lua.LState) int {
+	objectID1 := checkObjectID(L, 1)
+	objectID2 := checkObjectID(L, 2)
+
+	L.Push(lua.LBool(bytes.Compare(objectID1.OID[:], objectID2.OID[:]) <= 0))
+	return 1
+}
+
+func objectIDHexMethod(L *lua.LState) int {
+	objectID := checkObjectID(L, 1)
+
+	L.Push(lua.LString(objectID.OID.Hex()))
+	return 1
+}
+
+func objectIDGetTimestampMethod(L *lua.LState) int {
+	objectID := checkObjectID(L, 1)
+
+	L.Push(LDateTime(L, primitive.NewDateTimeFromTime(objectID.OID.Timestamp())))
+	return 1
+}
+
+// objectIDFromTimeFunc creates ObjectID at seconds or DateTime, zeroRest
+// clears the bytes after the timestamp to bound _id range queries
+func objectIDFromTimeFunc(L *lua.LState) int {
+	var t time.Time
+	if n, ok := L.Get(1).(lua.LNumber); ok {
+		t = time.Unix(int64(n), 0)
+	} else {
+		t = checkDateTime(L, 1).DT.Time()
+	}
+	zeroRest := L.OptBool(2, false)
+
+	var oid primitive.ObjectID
+	if zeroRest {
+		binary.BigEndian.PutUint32(oid[0:4], uint32(t.Unix()))
+	} else {
+		oid = primitive.NewObjectIDFromTimestamp(t)
+	}
+
+	L.Push(LObjectID(L, oid))
+	return 1
+}
+
+func objectIDIsValidFunc(L *lua.LState) int {
+	str, ok := L.Get(1).(lua.LString)
+
+	L.Push(lua.LBool(ok && primitive.IsValidObjectID(string(str))))
+	return 1
+}
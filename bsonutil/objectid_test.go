@@ -39,3 +39,35 @@ func TestObjectID(t *testing.T) {
 	`
 	require.Error(L.DoString(script))
 }
+
+func TestObjectIDMethods(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local oid = bson.ObjectID('6092e50e4ed1be4939967323')
+		local min = bson.ObjectID.fromTime(1620239630, true)
+		local next = bson.ObjectID.fromTime(bson.DateTime(1620239631000))
+		return oid:hex(), oid:getTimestamp():unixMillis(), min:hex(), min <= oid, oid < next,
+		  next <= oid, next:getTimestamp():unixMillis(), bson.ObjectID.isValid('6092e50e4ed1be4939967323'),
+		  bson.ObjectID.isValid('invalid'), bson.ObjectID.isValid(1)
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(10, L.GetTop())
+	assert.Equal("6092e50e4ed1be4939967323", L.ToString(1))
+	assert.Equal(lua.LNumber(1620239630000), L.Get(2))
+	assert.Equal("6092e50e0000000000000000", L.ToString(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+	assert.Equal(lua.LTrue, L.Get(5))
+	assert.Equal(lua.LFalse, L.Get(6))
+	assert.Equal(lua.LNumber(1620239631000), L.Get(7))
+	assert.Equal(lua.LTrue, L.Get(8))
+	assert.Equal(lua.LFalse, L.Get(9))
+	assert.Equal(lua.LFalse, L.Get(10))
+}
@@ -0,0 +1,263 @@
+package bsonutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bson types
+const (
+	REGEX_TYPENAME = "bson{regex}"
+)
+
+// regexOptions are the options supported by mongodb
+const regexOptions = "ilmsux"
+
+// Regex mongo
+type Regex struct {
+	R primitive.Regex
+}
+
+var regexMethods = map[string]lua.LGFunction{
+	"options": regexOptionsMethod,
+	"pattern": regexPatternMethod,
+}
+
+var regexFuncs = map[string]lua.LGFunction{
+	"fromLuaPattern": regexFromLuaPatternFunc,
+}
+
+// NewRegex new Regex for glua
+func NewRegex(L *lua.LState) int {
+	pattern := L.CheckString(1)
+	opts, err := toRegexOptions(L.OptString(2, ""))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	L.Push(LRegex(L, primitive.Regex{Pattern: pattern, Options: opts}))
+	return 1
+}
+
+// LRegex creates Regex value for glua
+func LRegex(L *lua.LState, r primitive.Regex) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Regex{R: r}
+	L.SetMetatable(ud, L.GetTypeMetatable(REGEX_TYPENAME))
+	return ud
+}
+
+// toRegexOptions checks the options and sorts them as bson requires
+func toRegexOptions(opts string) (string, error) {
+	b := []byte(opts)
+	for _, c := range b {
+		if !strings.ContainsRune(regexOptions, rune(c)) {
+			return "", fmt.Errorf("invalid option %q", c)
+		}
+	}
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return string(b), nil
+}
+
+func checkRegex(L *lua.LState, idx int) *Regex {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Regex); ok {
+		return v
+	}
+	L.ArgError(idx, "bson regex expected")
+	return nil
+}
+
+func regexOptionsMethod(L *lua.LState) int {
+	r := checkRegex(L, 1)
+
+	L.Push(lua.LString(r.R.Options))
+	return 1
+}
+
+func regexPatternMethod(L *lua.LState) int {
+	r := checkRegex(L, 1)
+
+	L.Push(lua.LString(r.R.Pattern))
+	return 1
+}
+
+func regexToStringMethod(L *lua.LState) int {
+	r := checkRegex(L, 1)
+
+	L.Push(lua.LString(fmt.Sprintf("/%s/%s", r.R.Pattern, r.R.Options)))
+	return 1
+}
+
+func regexEqMethod(L *lua.LState) int {
+	r1 := checkRegex(L, 1)
+	r2 := checkRegex(L, 2)
+
+	L.Push(lua.LBool(r1.R.Equal(r2.R)))
+	return 1
+}
+
+// luaPatternClasses maps lua character classes to posix classes
+var luaPatternClasses = map[byte]string{
+	'a': "alpha",
+	'c': "cntrl",
+	'd': "digit",
+	'l': "lower",
+	'p': "punct",
+	's': "space",
+	'u': "upper",
+	'w': "alnum",
+	'x': "xdigit",
+}
+
+// luaPatternClass translates %c escape, inSet is true within [...]
+func luaPatternClass(c byte, inSet bool) (string, error) {
+	lower := c | 0x20
+	if name, ok := luaPatternClasses[lower]; ok {
+		negate := c != lower
+		switch {
+		case inSet && negate:
+			return "[:^" + name + ":]", nil
+		case inSet:
+			return "[:" + name + ":]", nil
+		case negate:
+			return "[^[:" + name + ":]]", nil
+		}
+		return "[[:" + name + ":]]", nil
+	}
+	switch {
+	case c >= '1' && c <= '9' && !inSet:
+		return "\\" + string(c), nil
+	case c == 'b', c == 'f':
+		return "", fmt.Errorf("%%%c is not supported", c)
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return "", fmt.Errorf("invalid escape %%%c", c)
+	}
+	return "\\" + string(c), nil
+}
+
+// LuaPatternToRegex translates lua pattern to PCRE, %b, %f and position
+// captures have no equivalent
+func LuaPatternToRegex(pattern string) (string, error) {
+	var sb strings.Builder
+	// single tells whether the last item is a single character class, which a
+	// quantifier applies to, otherwise lua reads a quantifier as a literal
+	single := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		quantifier := c == '*' || c == '+' || c == '?' || c == '-'
+		if quantifier && !single {
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+			single = true
+			continue
+		}
+		single = true
+		switch c {
+		case '%':
+			i++
+			if i >= len(pattern) {
+				return "", errors.New("malformed pattern (ends with '%')")
+			}
+			s, err := luaPatternClass(pattern[i], false)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+			// a back reference takes no quantifier
+			single = pattern[i] < '1' || pattern[i] > '9'
+		case '[':
+			sb.WriteByte('[')
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				sb.WriteByte('^')
+				i++
+			}
+			// a leading ] is literal in lua
+			for first := true; ; first = false {
+				if i >= len(pattern) {
+					return "", errors.New("malformed pattern (missing ']')")
+				}
+				c = pattern[i]
+				if c == ']' && !first {
+					break
+				}
+				switch c {
+				case '%':
+					i++
+					if i >= len(pattern) {
+						return "", errors.New("malformed pattern (ends with '%')")
+					}
+					s, err := luaPatternClass(pattern[i], true)
+					if err != nil {
+						return "", err
+					}
+					sb.WriteString(s)
+				case '\\', '[', ']':
+					sb.WriteByte('\\')
+					sb.WriteByte(c)
+				default:
+					sb.WriteByte(c)
+				}
+				i++
+			}
+			sb.WriteByte(']')
+		case '^':
+			if i == 0 {
+				sb.WriteByte(c)
+				single = false
+			} else {
+				sb.WriteString("\\^")
+			}
+		case '$':
+			if i == len(pattern)-1 {
+				sb.WriteByte(c)
+				single = false
+			} else {
+				sb.WriteString("\\$")
+			}
+		case '-':
+			sb.WriteString("*?")
+			single = false
+		case '*', '+', '?':
+			sb.WriteByte(c)
+			single = false
+		case '(', ')':
+			if c == '(' && i+1 < len(pattern) && pattern[i+1] == ')' {
+				return "", errors.New("position captures are not supported")
+			}
+			sb.WriteByte(c)
+			single = false
+		case '\\', '{', '}', '|':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// regexFromLuaPatternFunc creates Regex from lua pattern, e.g.
+// mongo.Regex.fromLuaPattern("^%d+$")
+func regexFromLuaPatternFunc(L *lua.LState) int {
+	pattern, err := LuaPatternToRegex(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+		return 0
+	}
+	opts, err := toRegexOptions(L.OptString(2, ""))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	L.Push(LRegex(L, primitive.Regex{Pattern: pattern, Options: opts}))
+	return 1
+}
@@ -0,0 +1,84 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRegex(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local r = bson.Regex("^abc", "si")
+		local l = bson.Regex.fromLuaPattern("^%a+%-%d-$", "i")
+		return r:pattern(), r:options(), tostring(r), r == bson.Regex("^abc", "is"), l:pattern()
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(5, L.GetTop())
+	assert.Equal("^abc", L.ToString(1))
+	assert.Equal("is", L.ToString(2))
+	assert.Equal("/^abc/is", L.ToString(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+	assert.Equal(`^[[:alpha:]]+\-[[:digit:]]*?$`, L.ToString(5))
+
+	for _, script := range []string{
+		`return require('bson').Regex("a", "z")`,
+		`return require('bson').Regex.fromLuaPattern("%b()")`,
+		`return require('bson').Regex.fromLuaPattern("[a")`,
+	} {
+		assert.Error(L.DoString(script), script)
+	}
+}
+
+func TestLuaPatternToRegex(t *testing.T) {
+	assert := assert.New(t)
+
+	for pattern, expected := range map[string]string{
+		"a.b":          "a.b",
+		"^%s*(.-)%s*$": `^[[:space:]]*(.*?)[[:space:]]*$`,
+		"[%w_]+":       `[[:alnum:]_]+`,
+		"[^%D]":        `[^[:^digit:]]`,
+		"[]x]":         `[\]x]`,
+		"a^b$c":        `a\^b\$c`,
+		"{|}\\":        `\{\|\}\\`,
+		"(a)%1":        `(a)\1`,
+		"%.%%":         `\.\%`,
+		"-x":           `\-x`,
+		"(-x)":         `(\-x)`,
+		"|-":           `\|*?`,
+		"^*a**":        `^\*a*\*`,
+		"(a)%1-":       `(a)\1\-`,
+	} {
+		regex, err := LuaPatternToRegex(pattern)
+		assert.NoError(err, pattern)
+		assert.Equal(expected, regex, pattern)
+	}
+
+	_, err := LuaPatternToRegex("()")
+	assert.Error(err)
+	_, err = LuaPatternToRegex("%")
+	assert.Error(err)
+}
+
+func TestRegexValue(t *testing.T) {
+	assert := assert.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	r := primitive.Regex{Pattern: "^a", Options: "i"}
+	lv := ToLuaValue(L, r)
+	assert.Equal(r, Value(L, lv))
+}
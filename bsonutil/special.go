@@ -0,0 +1,351 @@
+package bsonutil
+
+import (
+	"fmt"
+	"reflect"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bson types
+const (
+	MINKEY_TYPENAME        = "bson{minkey}"
+	MAXKEY_TYPENAME        = "bson{maxkey}"
+	UNDEFINED_TYPENAME     = "bson{undefined}"
+	CODE_TYPENAME          = "bson{code}"
+	CODEWITHSCOPE_TYPENAME = "bson{codewithscope}"
+	DBPOINTER_TYPENAME     = "bson{dbpointer}"
+	SYMBOL_TYPENAME        = "bson{symbol}"
+)
+
+// MinKey mongo
+type MinKey struct {
+}
+
+// MaxKey mongo
+type MaxKey struct {
+}
+
+// Undefined mongo, deprecated
+type Undefined struct {
+}
+
+// Code mongo
+type Code struct {
+	JS primitive.JavaScript
+}
+
+// CodeWithScope mongo
+type CodeWithScope struct {
+	CWS primitive.CodeWithScope
+}
+
+// DBPointer mongo, deprecated
+type DBPointer struct {
+	DBP primitive.DBPointer
+}
+
+// Symbol mongo, deprecated
+type Symbol struct {
+	S primitive.Symbol
+}
+
+var codeMethods = map[string]lua.LGFunction{
+	"code": codeCodeMethod,
+}
+
+var codeWithScopeMethods = map[string]lua.LGFunction{
+	"code":  codeWithScopeCodeMethod,
+	"scope": codeWithScopeScopeMethod,
+}
+
+var dbPointerMethods = map[string]lua.LGFunction{
+	"db": dbPointerDBMethod,
+	"id": dbPointerIDMethod,
+}
+
+var symbolMethods = map[string]lua.LGFunction{
+	"symbol": symbolSymbolMethod,
+}
+
+// NewCode new Code for glua
+func NewCode(L *lua.LState) int {
+	code := L.CheckString(1)
+
+	L.Push(LCode(L, primitive.JavaScript(code)))
+	return 1
+}
+
+// NewCodeWithScope new CodeWithScope for glua, the scope is a document as
+// table, doc or extended json string
+func NewCodeWithScope(L *lua.LState) int {
+	code := L.CheckString(1)
+	scope := CastBSON(L, 2)
+	switch scope.(type) {
+	case []interface{}, bson.A:
+		L.ArgError(2, "scope document expected")
+		return 0
+	}
+
+	L.Push(LCodeWithScope(L, primitive.CodeWithScope{
+		Code:  primitive.JavaScript(code),
+		Scope: scope,
+	}))
+	return 1
+}
+
+// NewDBPointer new DBPointer for glua, id is ObjectID or hex string
+func NewDBPointer(L *lua.LState) int {
+	db := L.CheckString(1)
+
+	var oid primitive.ObjectID
+	if L.Get(2).Type() == lua.LTString {
+		var err error
+		oid, err = primitive.ObjectIDFromHex(L.ToString(2))
+		if err != nil {
+			L.ArgError(2, err.Error())
+			return 0
+		}
+	} else {
+		oid = checkObjectID(L, 2).OID
+	}
+
+	L.Push(LDBPointer(L, primitive.DBPointer{DB: db, Pointer: oid}))
+	return 1
+}
+
+// NewSymbol new Symbol for glua
+func NewSymbol(L *lua.LState) int {
+	s := L.CheckString(1)
+
+	L.Push(LSymbol(L, primitive.Symbol(s)))
+	return 1
+}
+
+// singleton returns the value of a valueless type, which is created once and
+// kept in the registry, so that the values are the same for rawequal
+func singleton(L *lua.LState, typename string, value interface{}) *lua.LUserData {
+	key := typename + ".singleton"
+	if ud, ok := L.G.Registry.RawGetString(key).(*lua.LUserData); ok {
+		return ud
+	}
+	ud := L.NewUserData()
+	ud.Value = value
+	L.SetMetatable(ud, L.GetTypeMetatable(typename))
+	L.G.Registry.RawSetString(key, ud)
+	return ud
+}
+
+// LMinKey returns MinKey value for glua
+func LMinKey(L *lua.LState) *lua.LUserData {
+	return singleton(L, MINKEY_TYPENAME, &MinKey{})
+}
+
+// LMaxKey returns MaxKey value for glua
+func LMaxKey(L *lua.LState) *lua.LUserData {
+	return singleton(L, MAXKEY_TYPENAME, &MaxKey{})
+}
+
+// LUndefined returns Undefined value for glua
+func LUndefined(L *lua.LState) *lua.LUserData {
+	return singleton(L, UNDEFINED_TYPENAME, &Undefined{})
+}
+
+// LCode creates Code value for glua
+func LCode(L *lua.LState, js primitive.JavaScript) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Code{JS: js}
+	L.SetMetatable(ud, L.GetTypeMetatable(CODE_TYPENAME))
+	return ud
+}
+
+// LCodeWithScope creates CodeWithScope value for glua
+func LCodeWithScope(L *lua.LState, cws primitive.CodeWithScope) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &CodeWithScope{CWS: cws}
+	L.SetMetatable(ud, L.GetTypeMetatable(CODEWITHSCOPE_TYPENAME))
+	return ud
+}
+
+// LDBPointer creates DBPointer value for glua
+func LDBPointer(L *lua.LState, dbp primitive.DBPointer) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &DBPointer{DBP: dbp}
+	L.SetMetatable(ud, L.GetTypeMetatable(DBPOINTER_TYPENAME))
+	return ud
+}
+
+// LSymbol creates Symbol value for glua
+func LSymbol(L *lua.LState, s primitive.Symbol) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = &Symbol{S: s}
+	L.SetMetatable(ud, L.GetTypeMetatable(SYMBOL_TYPENAME))
+	return ud
+}
+
+func checkCode(L *lua.LState, idx int) *Code {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Code); ok {
+		return v
+	}
+	L.ArgError(idx, "bson code expected")
+	return nil
+}
+
+func checkCodeWithScope(L *lua.LState, idx int) *CodeWithScope {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*CodeWithScope); ok {
+		return v
+	}
+	L.ArgError(idx, "bson codewithscope expected")
+	return nil
+}
+
+func checkDBPointer(L *lua.LState, idx int) *DBPointer {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*DBPointer); ok {
+		return v
+	}
+	L.ArgError(idx, "bson dbpointer expected")
+	return nil
+}
+
+func checkSymbol(L *lua.LState, idx int) *Symbol {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Symbol); ok {
+		return v
+	}
+	L.ArgError(idx, "bson symbol expected")
+	return nil
+}
+
+func codeCodeMethod(L *lua.LState) int {
+	code := checkCode(L, 1)
+
+	L.Push(lua.LString(code.JS))
+	return 1
+}
+
+func codeWithScopeCodeMethod(L *lua.LState) int {
+	cws := checkCodeWithScope(L, 1)
+
+	L.Push(lua.LString(cws.CWS.Code))
+	return 1
+}
+
+func codeWithScopeScopeMethod(L *lua.LState) int {
+	cws := checkCodeWithScope(L, 1)
+
+	L.Push(ToLuaValue(L, cws.CWS.Scope))
+	return 1
+}
+
+func dbPointerDBMethod(L *lua.LState) int {
+	dbp := checkDBPointer(L, 1)
+
+	L.Push(lua.LString(dbp.DBP.DB))
+	return 1
+}
+
+func dbPointerIDMethod(L *lua.LState) int {
+	dbp := checkDBPointer(L, 1)
+
+	L.Push(LObjectID(L, dbp.DBP.Pointer))
+	return 1
+}
+
+func symbolSymbolMethod(L *lua.LState) int {
+	s := checkSymbol(L, 1)
+
+	L.Push(lua.LString(s.S))
+	return 1
+}
+
+// singletonEqMethod compares the valueless types, both operands share the
+// metatable to get here
+func singletonEqMethod(L *lua.LState) int {
+	L.Push(lua.LBool(L.GetMetatable(L.Get(1)) == L.GetMetatable(L.Get(2))))
+	return 1
+}
+
+func codeEqMethod(L *lua.LState) int {
+	code1 := checkCode(L, 1)
+	code2 := checkCode(L, 2)
+
+	L.Push(lua.LBool(code1.JS == code2.JS))
+	return 1
+}
+
+func codeWithScopeEqMethod(L *lua.LState) int {
+	cws1 := checkCodeWithScope(L, 1)
+	cws2 := checkCodeWithScope(L, 2)
+
+	L.Push(lua.LBool(cws1.CWS.Code == cws2.CWS.Code && reflect.DeepEqual(cws1.CWS.Scope, cws2.CWS.Scope)))
+	return 1
+}
+
+func dbPointerEqMethod(L *lua.LState) int {
+	dbp1 := checkDBPointer(L, 1)
+	dbp2 := checkDBPointer(L, 2)
+
+	L.Push(lua.LBool(dbp1.DBP.Equal(dbp2.DBP)))
+	return 1
+}
+
+func symbolEqMethod(L *lua.LState) int {
+	s1 := checkSymbol(L, 1)
+	s2 := checkSymbol(L, 2)
+
+	L.Push(lua.LBool(s1.S == s2.S))
+	return 1
+}
+
+func minKeyToStringMethod(L *lua.LState) int {
+	L.Push(lua.LString("MinKey"))
+	return 1
+}
+
+func maxKeyToStringMethod(L *lua.LState) int {
+	L.Push(lua.LString("MaxKey"))
+	return 1
+}
+
+func undefinedToStringMethod(L *lua.LState) int {
+	L.Push(lua.LString("undefined"))
+	return 1
+}
+
+func codeToStringMethod(L *lua.LState) int {
+	code := checkCode(L, 1)
+
+	L.Push(lua.LString(fmt.Sprintf("Code(%q)", code.JS)))
+	return 1
+}
+
+func codeWithScopeToStringMethod(L *lua.LState) int {
+	cws := checkCodeWithScope(L, 1)
+
+	scope, err := bson.MarshalExtJSON(cws.CWS.Scope, false, false)
+	if err != nil {
+		L.Push(lua.LString(fmt.Sprintf("CodeWithScope(%q, %v)", cws.CWS.Code, cws.CWS.Scope)))
+		return 1
+	}
+	L.Push(lua.LString(fmt.Sprintf("CodeWithScope(%q, %s)", cws.CWS.Code, scope)))
+	return 1
+}
+
+func dbPointerToStringMethod(L *lua.LState) int {
+	dbp := checkDBPointer(L, 1)
+
+	L.Push(lua.LString(fmt.Sprintf("DBPointer(%q, %s)", dbp.DBP.DB, dbp.DBP.Pointer.String())))
+	return 1
+}
+
+func symbolToStringMethod(L *lua.LState) int {
+	s := checkSymbol(L, 1)
+
+	L.Push(lua.LString(fmt.Sprintf("Symbol(%q)", s.S)))
+	return 1
+}
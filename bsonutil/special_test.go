@@ -0,0 +1,154 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSpecialTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local oid = bson.ObjectID("6ad47761a7c74944f6836233")
+		local cws = bson.CodeWithScope("return x", {x = 1})
+		local p = bson.DBPointer("test.foo", oid)
+		return tostring(bson.MinKey), tostring(bson.MaxKey), tostring(bson.Undefined),
+		  bson.MinKey == bson.MinKey, bson.MinKey == bson.MaxKey,
+		  bson.Code("return 1"):code(), tostring(bson.Code("f()")), cws:code(), cws:scope().x,
+		  tostring(cws), p:db(), p:id() == oid, p == bson.DBPointer("test.foo", "6ad47761a7c74944f6836233"),
+		  tostring(p), bson.Symbol("foo"):symbol(), tostring(bson.Symbol("foo"))
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(16, L.GetTop())
+	assert.Equal("MinKey", L.ToString(1))
+	assert.Equal("MaxKey", L.ToString(2))
+	assert.Equal("undefined", L.ToString(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+	assert.Equal(lua.LFalse, L.Get(5))
+	assert.Equal("return 1", L.ToString(6))
+	assert.Equal(`Code("f()")`, L.ToString(7))
+	assert.Equal("return x", L.ToString(8))
+	assert.Equal(lua.LNumber(1), L.Get(9))
+	assert.Equal(`CodeWithScope("return x", {"x":1})`, L.ToString(10))
+	assert.Equal("test.foo", L.ToString(11))
+	assert.Equal(lua.LTrue, L.Get(12))
+	assert.Equal(lua.LTrue, L.Get(13))
+	assert.Equal(`DBPointer("test.foo", ObjectID("6ad47761a7c74944f6836233"))`, L.ToString(14))
+	assert.Equal("foo", L.ToString(15))
+	assert.Equal(`Symbol("foo")`, L.ToString(16))
+}
+
+func TestCodeWithScopeScope(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	require.NoError(L.DoString(`
+		local bson = require 'bson'
+		return bson.CodeWithScope("x", {}), bson.CodeWithScope("x", '{"a": 1}')
+	`))
+	require.Equal(2, L.GetTop())
+	for i := 1; i <= 2; i++ {
+		_, err := bson.Marshal(bson.D{{Key: "cws", Value: GetValue(L, i)}})
+		assert.NoError(err)
+	}
+	assert.Equal(map[string]interface{}{}, GetValue(L, 1).(primitive.CodeWithScope).Scope)
+	assert.Equal(bson.D{{Key: "a", Value: int32(1)}}, GetValue(L, 2).(primitive.CodeWithScope).Scope)
+
+	assert.Error(L.DoString(`return require('bson').CodeWithScope("x", {1, 2})`))
+}
+
+func TestSingletons(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	require.NoError(L.DoString(`bson = require 'bson'`))
+	L.SetGlobal("doc", ToLuaValue(L, bson.D{
+		{Key: "min", Value: primitive.MinKey{}},
+		{Key: "max", Value: primitive.MaxKey{}},
+		{Key: "undefined", Value: primitive.Undefined{}},
+	}))
+	require.NoError(L.DoString(`
+		return rawequal(doc.min, bson.MinKey), rawequal(doc.max, bson.MaxKey),
+		  rawequal(doc.undefined, bson.Undefined), rawequal(doc.min, bson.MaxKey)
+	`))
+	require.Equal(4, L.GetTop())
+	assert.Equal(lua.LTrue, L.Get(1))
+	assert.Equal(lua.LTrue, L.Get(2))
+	assert.Equal(lua.LTrue, L.Get(3))
+	assert.Equal(lua.LFalse, L.Get(4))
+}
+
+func TestToLuaValueAllTypes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	L := lua.NewState()
+	defer L.Close()
+	RegisterType(L)
+
+	oid, err := primitive.ObjectIDFromHex("6ad47761a7c74944f6836233")
+	require.NoError(err)
+	dec, err := primitive.ParseDecimal128("1.5")
+	require.NoError(err)
+	doc := bson.D{
+		{Key: "double", Value: 1.5},
+		{Key: "string", Value: "foo"},
+		{Key: "document", Value: bson.D{{Key: "a", Value: int32(1)}}},
+		{Key: "array", Value: bson.A{int32(1), "b"}},
+		{Key: "binary", Value: primitive.Binary{Subtype: 0x80, Data: []byte("foo")}},
+		{Key: "undefined", Value: primitive.Undefined{}},
+		{Key: "objectid", Value: oid},
+		{Key: "bool", Value: true},
+		{Key: "datetime", Value: primitive.DateTime(1620277291038)},
+		{Key: "null", Value: primitive.Null{}},
+		{Key: "regex", Value: primitive.Regex{Pattern: "^a", Options: "i"}},
+		{Key: "dbpointer", Value: primitive.DBPointer{DB: "test.foo", Pointer: oid}},
+		{Key: "code", Value: primitive.JavaScript("f()")},
+		{Key: "symbol", Value: primitive.Symbol("foo")},
+		{Key: "codewithscope", Value: primitive.CodeWithScope{Code: "f()", Scope: bson.D{{Key: "x", Value: int32(1)}}}},
+		{Key: "int32", Value: int32(1)},
+		{Key: "timestamp", Value: primitive.Timestamp{T: 1, I: 2}},
+		{Key: "int64", Value: int64(1)},
+		{Key: "decimal128", Value: dec},
+		{Key: "minkey", Value: primitive.MinKey{}},
+		{Key: "maxkey", Value: primitive.MaxKey{}},
+	}
+	b, err := bson.Marshal(doc)
+	require.NoError(err)
+	var decoded bson.D
+	require.NoError(bson.Unmarshal(b, &decoded))
+
+	lv := ToLuaValue(L, decoded)
+	m, ok := Value(L, lv).(map[string]interface{})
+	require.True(ok)
+	for _, e := range doc {
+		switch e.Key {
+		case "document", "array", "null", "int32", "int64", "double", "codewithscope":
+			continue
+		}
+		assert.Equal(e.Value, m[e.Key], e.Key)
+	}
+	assert.Equal(primitive.JavaScript("f()"), m["codewithscope"].(primitive.CodeWithScope).Code)
+	_, ok = m["null"]
+	assert.False(ok)
+}
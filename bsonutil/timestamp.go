@@ -0,0 +1,70 @@
+package bsonutil
+
+import (
+	"math"
+
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var timestampMethods = map[string]lua.LGFunction{
+	"i":          timestampIMethod,
+	"next":       timestampNextMethod,
+	"t":          timestampTMethod,
+	"toDateTime": timestampToDateTimeMethod,
+}
+
+func timestampIMethod(L *lua.LState) int {
+	ts := checkTimestamp(L, 1)
+
+	L.Push(lua.LNumber(ts.Ts.I))
+	return 1
+}
+
+func timestampTMethod(L *lua.LState) int {
+	ts := checkTimestamp(L, 1)
+
+	L.Push(lua.LNumber(ts.Ts.T))
+	return 1
+}
+
+// timestampNextMethod returns the following timestamp, the ordinal carries
+// into the next second
+func timestampNextMethod(L *lua.LState) int {
+	ts := checkTimestamp(L, 1)
+
+	next := ts.Ts
+	if next.I == math.MaxUint32 {
+		next.T++
+		next.I = 0
+	} else {
+		next.I++
+	}
+
+	L.Push(LTimestamp(L, next))
+	return 1
+}
+
+// timestampToDateTimeMethod returns the DateTime of the seconds part
+func timestampToDateTimeMethod(L *lua.LState) int {
+	ts := checkTimestamp(L, 1)
+
+	L.Push(LDateTime(L, primitive.DateTime(int64(ts.Ts.T)*1000)))
+	return 1
+}
+
+func timestampLtMethod(L *lua.LState) int {
+	ts1 := checkTimestamp(L, 1)
+	ts2 := checkTimestamp(L, 2)
+
+	L.Push(lua.LBool(primitive.CompareTimestamp(ts1.Ts, ts2.Ts) < 0))
+	return 1
+}
+
+func timestampLeMethod(L *lua.LState) int {
+	ts1 := checkTimestamp(L, 1)
+	ts2 := checkTimestamp(L, 2)
+
+	L.Push(lua.LBool(primitive.CompareTimestamp(ts1.Ts, ts2.Ts) <= 0))
+	return 1
+}
@@ -0,0 +1,41 @@
+package bsonutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTimestampMethods(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	Preload(L)
+
+	script := `
+		local bson = require 'bson'
+		local ts = bson.Timestamp(1620277291, 1)
+		local last = bson.Timestamp(1620277291, 4294967295)
+		return ts:t(), ts:i(), ts:toDateTime():unixMillis(), ts < ts:next(), ts:next() <= ts,
+		  ts <= bson.Timestamp(1620277291, 1), tostring(last:next()), bson.Timestamp() < ts
+	`
+	require.NoError(L.DoString(script))
+	require.Equal(8, L.GetTop())
+	assert.Equal(lua.LNumber(1620277291), L.Get(1))
+	assert.Equal(lua.LNumber(1), L.Get(2))
+	assert.Equal(lua.LNumber(1620277291000), L.Get(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+	assert.Equal(lua.LFalse, L.Get(5))
+	assert.Equal(lua.LTrue, L.Get(6))
+	assert.Equal("Timestamp(1620277292, 0)", L.ToString(7))
+	assert.Equal(lua.LTrue, L.Get(8))
+
+	require.NoError(L.DoString(`return require('bson').Timestamp()`))
+	assert.Equal(primitive.Timestamp{}, GetValue(L, -1))
+}
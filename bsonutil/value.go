@@ -8,6 +8,7 @@ import (
 	"unicode"
 
 	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -65,10 +66,40 @@ func Value(l *lua.LState, v lua.LValue) interface{} {
 		case *DateTime:
 			return udt.DT
 		case *Timestamp:
+			// the zero timestamp is kept, as a placeholder filled by server
 			return udt.Ts
 		case *Null:
-			// TODO: consts value
-			return nil
+			return primitive.Null{}
+		case *Int32:
+			return udt.V
+		case *Int64:
+			return udt.V
+		case *Double:
+			return udt.V
+		case *Decimal128:
+			return udt.D
+		case *Binary:
+			return udt.B
+		case *Regex:
+			return udt.R
+		case *MinKey:
+			return primitive.MinKey{}
+		case *MaxKey:
+			return primitive.MaxKey{}
+		case *Undefined:
+			return primitive.Undefined{}
+		case *Code:
+			return udt.JS
+		case *CodeWithScope:
+			return udt.CWS
+		case *DBPointer:
+			return udt.DBP
+		case *Symbol:
+			return udt.S
+		case *Doc:
+			return udt.D(func(v lua.LValue) interface{} {
+				return Value(l, v)
+			})
 		}
 		panic(fmt.Sprintf("unknown lua userdata type: %s", t))
 	default:
@@ -76,8 +107,74 @@ func Value(l *lua.LState, v lua.LValue) interface{} {
 	}
 }
 
+// OrderedValue converts glua vm value to go value like Value, but documents
+// are converted to bson.D following the key insertion order of the tables
+func OrderedValue(l *lua.LState, v lua.LValue) interface{} {
+	tb, ok := v.(*lua.LTable)
+	if !ok {
+		if ud, ok := v.(*lua.LUserData); ok {
+			if doc, ok := ud.Value.(*Doc); ok {
+				return doc.D(func(v lua.LValue) interface{} {
+					return OrderedValue(l, v)
+				})
+			}
+		}
+		return Value(l, v)
+	}
+
+	var d bson.D
+	arrSize := 0
+	// Next iterates the hash part in key insertion order
+	for k, val := tb.Next(lua.LNil); k != lua.LNil; k, val = tb.Next(k) {
+		key := Value(l, k)
+		if keyi, ok := key.(int); ok {
+			if keyi < 1 {
+				// not an array index
+				arrSize = -1
+			} else if arrSize >= 0 && arrSize < keyi {
+				arrSize = keyi
+			}
+			key = strconv.Itoa(keyi)
+		} else {
+			arrSize = -1
+		}
+		d = append(d, bson.E{Key: key.(string), Value: OrderedValue(l, val)})
+	}
+
+	if arrSize >= 0 {
+		ms := make([]interface{}, arrSize)
+		for _, e := range d {
+			i, _ := strconv.Atoi(e.Key)
+			ms[i-1] = e.Value
+		}
+		return ms
+	}
+
+	return d
+}
+
+// DecodeOptions controls the conversion from bson values to glua values
+type DecodeOptions struct {
+	// Ordered converts documents to bson{doc} keeping the key order
+	Ordered bool
+	// Int64 converts int64 values beyond the lua number precision to
+	// bson{int64}
+	Int64 bool
+	// Null converts null values to bson{null} instead of nil, so that null
+	// fields can be told from missing ones
+	Null bool
+}
+
 // ToLuaValue converts go value to glua vm value
 func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
+	return ToLuaValueWith(l, i, nil)
+}
+
+// ToLuaValueWith converts go value to glua vm value with the decode options
+func ToLuaValueWith(l *lua.LState, i interface{}, opts *DecodeOptions) lua.LValue {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
 	if i == nil {
 		return lua.LNil
 	}
@@ -89,9 +186,30 @@ func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
 		return LDateTime(l, ii)
 	case primitive.Timestamp:
 		return LTimestamp(l, ii)
+	case primitive.Decimal128:
+		return LDecimal128(l, ii)
+	case primitive.Binary:
+		return LBinary(l, ii)
+	case primitive.Regex:
+		return LRegex(l, ii)
+	case primitive.MinKey:
+		return LMinKey(l)
+	case primitive.MaxKey:
+		return LMaxKey(l)
+	case primitive.Undefined:
+		return LUndefined(l)
+	case primitive.JavaScript:
+		return LCode(l, ii)
+	case primitive.CodeWithScope:
+		return LCodeWithScope(l, ii)
+	case primitive.DBPointer:
+		return LDBPointer(l, ii)
+	case primitive.Symbol:
+		return LSymbol(l, ii)
 	case primitive.Null:
-		// TODO: return LNull from module.LNull
-		// return LNull(l)
+		if opts.Null {
+			return LNull(l)
+		}
 		return lua.LNil
 	case bool:
 		return lua.LBool(ii)
@@ -104,6 +222,9 @@ func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
 	case int32:
 		return lua.LNumber(ii)
 	case int64:
+		if opts.Int64 && (ii > maxSafeInteger || ii < -maxSafeInteger) {
+			return LInt64(l, ii)
+		}
 		return lua.LNumber(ii)
 	case uint:
 		return lua.LNumber(ii)
@@ -123,6 +244,25 @@ func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
 		return lua.LString(ii)
 	case []byte:
 		return lua.LString(ii)
+	case primitive.D:
+		if opts.Ordered {
+			doc := &Doc{Values: make(map[string]lua.LValue, len(ii))}
+			for _, e := range ii {
+				doc.set(e.Key, elemToLuaValue(l, e.Value, opts))
+			}
+			return LDoc(l, doc)
+		}
+		tb := l.NewTable()
+		for _, e := range ii {
+			tb.RawSetString(e.Key, elemToLuaValue(l, e.Value, opts))
+		}
+		return tb
+	case primitive.A:
+		tb := l.NewTable()
+		for j, e := range ii {
+			tb.RawSetInt(j+1, elemToLuaValue(l, e, opts))
+		}
+		return tb
 	default:
 		v := reflect.ValueOf(i)
 		switch v.Kind() {
@@ -130,19 +270,19 @@ func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
 			if v.IsNil() {
 				return lua.LNil
 			}
-			return ToLuaValue(l, v.Elem().Interface())
+			return ToLuaValueWith(l, v.Elem().Interface(), opts)
 
 		case reflect.Struct:
-			return luaTableFromStruct(l, v)
+			return luaTableFromStructWith(l, v, opts)
 
 		case reflect.Map:
-			return luaTableFromMap(l, v)
+			return luaTableFromMapWith(l, v, opts)
 
 		case reflect.Slice:
-			return luaTableFromSlice(l, v)
+			return luaTableFromSliceWith(l, v, opts)
 
 		case reflect.Array:
-			return luaTableFromSlice(l, v)
+			return luaTableFromSliceWith(l, v, opts)
 
 		default:
 			panic(fmt.Sprintf("unknown type being pushed onto lua stack: %T %+v", i, i))
@@ -150,12 +290,25 @@ func ToLuaValue(l *lua.LState, i interface{}) lua.LValue {
 	}
 }
 
+// elemToLuaValue converts an element of a decoded document or array, where
+// null values are decoded as nil
+func elemToLuaValue(l *lua.LState, i interface{}, opts *DecodeOptions) lua.LValue {
+	if i == nil && opts.Null {
+		return LNull(l)
+	}
+	return ToLuaValueWith(l, i, opts)
+}
+
 func luaTableFromStruct(l *lua.LState, v reflect.Value) lua.LValue {
+	return luaTableFromStructWith(l, v, nil)
+}
+
+func luaTableFromStructWith(l *lua.LState, v reflect.Value, opts *DecodeOptions) lua.LValue {
 	tb := l.NewTable()
-	return luaTableFromStructInner(l, tb, v)
+	return luaTableFromStructInner(l, tb, v, opts)
 }
 
-func luaTableFromStructInner(l *lua.LState, tb *lua.LTable, v reflect.Value) lua.LValue {
+func luaTableFromStructInner(l *lua.LState, tb *lua.LTable, v reflect.Value, opts *DecodeOptions) lua.LValue {
 	t := v.Type()
 	for j := 0; j < v.NumField(); j++ {
 		var inline bool
@@ -175,28 +328,36 @@ func luaTableFromStructInner(l *lua.LState, tb *lua.LTable, v reflect.Value) lua
 			}
 		}
 		if inline {
-			luaTableFromStructInner(l, tb, v.Field(j))
+			luaTableFromStructInner(l, tb, v.Field(j), opts)
 		} else {
-			tb.RawSetString(name, ToLuaValue(l, v.Field(j).Interface()))
+			tb.RawSetString(name, ToLuaValueWith(l, v.Field(j).Interface(), opts))
 		}
 	}
 	return tb
 }
 
 func luaTableFromMap(l *lua.LState, v reflect.Value) lua.LValue {
+	return luaTableFromMapWith(l, v, nil)
+}
+
+func luaTableFromMapWith(l *lua.LState, v reflect.Value, opts *DecodeOptions) lua.LValue {
 	tb := l.NewTable()
 	for _, k := range v.MapKeys() {
-		tb.RawSet(ToLuaValue(l, k.Interface()),
-			ToLuaValue(l, v.MapIndex(k).Interface()))
+		tb.RawSet(ToLuaValueWith(l, k.Interface(), opts),
+			ToLuaValueWith(l, v.MapIndex(k).Interface(), opts))
 	}
 	return tb
 }
 
 func luaTableFromSlice(l *lua.LState, v reflect.Value) lua.LValue {
+	return luaTableFromSliceWith(l, v, nil)
+}
+
+func luaTableFromSliceWith(l *lua.LState, v reflect.Value, opts *DecodeOptions) lua.LValue {
 	tb := l.NewTable()
 	for j := 0; j < v.Len(); j++ {
 		tb.RawSetInt(j+1, // because lua is 1-indexed
-			ToLuaValue(l, v.Index(j).Interface()))
+			ToLuaValueWith(l, v.Index(j).Interface(), opts))
 	}
 	return tb
 }
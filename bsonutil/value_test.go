@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestGetValue(t *testing.T) {
@@ -49,6 +51,18 @@ func TestGetValue(t *testing.T) {
 	}, i)
 }
 
+func TestGetUserDataValue(t *testing.T) {
+	assert := assert.New(t)
+
+	l := lua.NewState()
+	defer l.Close()
+
+	type foo struct{}
+	ud := l.NewUserData()
+	ud.Value = &foo{}
+	assert.Panics(func() { Value(l, ud) })
+}
+
 func TestToLuaValue(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -176,3 +190,65 @@ func TestTableFromSlice(t *testing.T) {
 		return true
 	`)
 }
+
+func TestOrderedValue(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	l := lua.NewState()
+	require.NotNil(l)
+	defer l.Close()
+
+	require.NoError(l.DoString(`
+		return {
+			find = "test",
+			filter = {b = 1, a = 2},
+			sort = {z = 1, y = -1, x = 1},
+			projection = {"a", "b"},
+			limit = 1,
+		}
+	`))
+	i := OrderedValue(l, l.Get(-1))
+	assert.Equal(bson.D{
+		{Key: "find", Value: "test"},
+		{Key: "filter", Value: bson.D{{Key: "b", Value: 1}, {Key: "a", Value: 2}}},
+		{Key: "sort", Value: bson.D{{Key: "z", Value: 1}, {Key: "y", Value: -1}, {Key: "x", Value: 1}}},
+		{Key: "projection", Value: []interface{}{"a", "b"}},
+		{Key: "limit", Value: 1},
+	}, i)
+
+	// keys below 1 are not array indexes
+	require.NoError(l.DoString(`return {[0] = "a", [1] = "b"}, {[-1] = 1}`))
+	assert.ElementsMatch(bson.D{{Key: "0", Value: "a"}, {Key: "1", Value: "b"}}, OrderedValue(l, l.Get(-2)))
+	assert.Equal(bson.D{{Key: "-1", Value: 1}}, OrderedValue(l, l.Get(-1)))
+}
+
+func TestNullValue(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	l := lua.NewState()
+	require.NotNil(l)
+	defer l.Close()
+	RegisterType(l)
+
+	doc := bson.D{{Key: "a", Value: nil}, {Key: "b", Value: bson.A{nil, primitive.Null{}}}}
+	lv := ToLuaValue(l, doc)
+	assert.Equal(map[string]interface{}{"b": []interface{}{}}, Value(l, lv))
+
+	lv = ToLuaValueWith(l, doc, &DecodeOptions{Null: true})
+	assert.Equal(map[string]interface{}{
+		"a": primitive.Null{},
+		"b": []interface{}{primitive.Null{}, primitive.Null{}},
+	}, Value(l, lv))
+
+	// only null values of decoded documents and arrays are bson{null}
+	assert.Equal(lua.LNil, ToLuaValueWith(l, nil, &DecodeOptions{Null: true}))
+	lv = ToLuaValueWith(l, map[string]interface{}{
+		"upsertedId": nil,
+		"doc":        bson.D{{Key: "a", Value: nil}},
+	}, &DecodeOptions{Null: true})
+	require.IsType(&lua.LTable{}, lv)
+	assert.Equal(lua.LNil, lv.(*lua.LTable).RawGetString("upsertedId"))
+	assert.Equal(map[string]interface{}{"a": primitive.Null{}}, Value(l, lv.(*lua.LTable).RawGetString("doc")))
+}
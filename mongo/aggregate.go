@@ -0,0 +1,125 @@
+package gluamongo_mongo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// aggregateOptions parses aggregate options, cursor = true or
+// cursor = {batchSize = n} returns a cursor instead of the results
+func aggregateOptions(opts interface{}) (*options.AggregateOptions, bool, error) {
+	ao := options.Aggregate()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, false, err
+	}
+	var useCursor bool
+	for key, v := range m {
+		var b bool
+		var d time.Duration
+		switch key {
+		case "allowDiskUse":
+			if b, err = toBool(v); err == nil {
+				ao.SetAllowDiskUse(b)
+			}
+		case "batchSize":
+			var i int32
+			if i, err = toInt32(v); err == nil {
+				ao.SetBatchSize(i)
+			}
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				ao.SetBypassDocumentValidation(b)
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				ao.SetCollation(c)
+			}
+		case "comment":
+			var str string
+			if str, err = toString(v); err == nil {
+				ao.SetComment(str)
+			}
+		case "cursor":
+			if b, err = toBool(v); err == nil {
+				useCursor = b
+			} else {
+				var cm map[string]interface{}
+				if cm, err = optionsMap(v); err == nil {
+					useCursor = true
+					if size, ok := cm["batchSize"]; ok {
+						var i int32
+						if i, err = toInt32(size); err == nil {
+							ao.SetBatchSize(i)
+						}
+					}
+				}
+			}
+		case "hint":
+			ao.SetHint(v)
+		case "maxAwaitTimeMS":
+			if d, err = toDuration(v); err == nil {
+				ao.SetMaxAwaitTime(d)
+			}
+		case "maxTimeMS":
+			if d, err = toDuration(v); err == nil {
+				ao.SetMaxTime(d)
+			}
+		case "let":
+			ao.SetLet(v)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return ao, useCursor, nil
+}
+
+// aggregate runs pipeline at 2 with options at 3, the results are loaded
+// into an array unless the cursor option is set. The pipeline is run before
+// the cursor is returned, so that $out or $merge stages take effect and the
+// errors are reported by aggregate.
+func aggregate(L *lua.LState, client *Client, target aggregator) int {
+	pipeline := bsonutil.CastBSON(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, useCursor, err := aggregateOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	query := &aggregateQuery{
+		target:   target,
+		pipeline: pipeline,
+		options:  opts,
+	}
+
+	ctx, cancel := client.SessionContext(sess)
+	defer cancel()
+
+	cur, err := query.open(ctx)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+	if useCursor {
+		pushCursor(L, client, sess, query).Cursor = cur
+		return 1
+	}
+
+	results, err := client.decodeAll(ctx, L, cur)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(results)
+	return 1
+}
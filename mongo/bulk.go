@@ -0,0 +1,505 @@
+package gluamongo_mongo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	BULK_TYPENAME     = "mongo{bulk}"
+	BULKFIND_TYPENAME = "mongo{bulkfind}"
+)
+
+// Bulk shell-style bulk operations builder
+type Bulk struct {
+	Collection  *Collection
+	Ordered     bool
+	Models      []mongo.WriteModel
+	InsertedIDs map[int64]interface{}
+	executed    bool
+}
+
+// BulkFind bulk operations builder for the matched documents
+type BulkFind struct {
+	Bulk         *lua.LUserData
+	Filter       interface{}
+	Upsert       bool
+	ArrayFilters *options.ArrayFilters
+	Collation    *options.Collation
+	Hint         interface{}
+}
+
+var bulkMethods = map[string]lua.LGFunction{
+	"execute": bulkExecuteMethod,
+	"find":    bulkFindMethod,
+	"insert":  bulkInsertMethod,
+}
+
+var bulkFindMethods = map[string]lua.LGFunction{
+	"arrayFilters": bulkFindArrayFiltersMethod,
+	"collation":    bulkFindCollationMethod,
+	"hint":         bulkFindHintMethod,
+	"upsert":       bulkFindUpsertMethod,
+
+	"delete":     bulkFindDeleteMethod,
+	"deleteOne":  bulkFindDeleteOneMethod,
+	"remove":     bulkFindDeleteMethod,
+	"removeOne":  bulkFindDeleteOneMethod,
+	"replaceOne": bulkFindReplaceOneMethod,
+	"update":     bulkFindUpdateMethod,
+	"updateOne":  bulkFindUpdateOneMethod,
+}
+
+func pushBulk(L *lua.LState, coll *Collection, ordered bool) {
+	ud := L.NewUserData()
+	ud.Value = &Bulk{
+		Collection:  coll,
+		Ordered:     ordered,
+		InsertedIDs: map[int64]interface{}{},
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(BULK_TYPENAME))
+	L.Push(ud)
+}
+
+func checkBulk(L *lua.LState) *Bulk {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*Bulk); ok {
+		if v.executed {
+			L.ArgError(1, "bulk operations already executed")
+			return nil
+		}
+		return v
+	}
+	L.ArgError(1, "mongo bulk expected")
+	return nil
+}
+
+func checkBulkFind(L *lua.LState) *BulkFind {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*BulkFind); ok {
+		return v
+	}
+	L.ArgError(1, "mongo bulk find expected")
+	return nil
+}
+
+// ensureID sets _id for documents without one, so inserted ids can be reported
+func ensureID(doc interface{}) (interface{}, interface{}) {
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if id, ok := d["_id"]; ok {
+			return d, id
+		}
+		id := primitive.NewObjectID()
+		d["_id"] = id
+		return d, id
+	case bson.D:
+		for _, e := range d {
+			if e.Key == "_id" {
+				return d, e.Value
+			}
+		}
+		id := primitive.NewObjectID()
+		return append(bson.D{{Key: "_id", Value: id}}, d...), id
+	}
+	return doc, nil
+}
+
+func bulkWriteOptions(opts interface{}) (*options.BulkWriteOptions, error) {
+	bo := options.BulkWrite()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "bypassDocumentValidation":
+			var b bool
+			if b, err = toBool(v); err == nil {
+				bo.SetBypassDocumentValidation(b)
+			}
+		case "ordered":
+			var b bool
+			if b, err = toBool(v); err == nil {
+				bo.SetOrdered(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return bo, nil
+}
+
+// writeModel converts bulkWrite operation, e.g. {insertOne = {document = ...}},
+// the inserted id is returned for insertOne operations
+func writeModel(op interface{}) (mongo.WriteModel, interface{}, error) {
+	m, err := optionsMap(op)
+	if err != nil || len(m) != 1 {
+		return nil, nil, fmt.Errorf("invalid operation: %v", op)
+	}
+	for name, v := range m {
+		spec, err := optionsMap(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s operation: %v", name, err)
+		}
+
+		f := &BulkFind{Filter: spec["filter"]}
+		for key, val := range spec {
+			switch key {
+			case "arrayFilters":
+				var af options.ArrayFilters
+				if af, err = toArrayFilters(val); err == nil {
+					f.ArrayFilters = &af
+				}
+			case "collation":
+				f.Collation, err = toCollation(val)
+			case "hint":
+				f.Hint = val
+			case "upsert":
+				f.Upsert, err = toBool(val)
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s option: %v", key, err)
+			}
+		}
+		if name != "insertOne" && f.Filter == nil {
+			return nil, nil, fmt.Errorf("filter required for %s operation", name)
+		}
+
+		switch name {
+		case "insertOne":
+			doc, ok := spec["document"]
+			if !ok {
+				return nil, nil, fmt.Errorf("document required for insertOne operation")
+			}
+			doc, id := ensureID(doc)
+			return mongo.NewInsertOneModel().SetDocument(doc), id, nil
+		case "updateOne":
+			return f.updateModel(spec["update"], false), nil, nil
+		case "updateMany":
+			return f.updateModel(spec["update"], true), nil, nil
+		case "replaceOne":
+			return f.replaceModel(spec["replacement"]), nil, nil
+		case "deleteOne":
+			return f.deleteModel(false), nil, nil
+		case "deleteMany":
+			return f.deleteModel(true), nil, nil
+		}
+		return nil, nil, fmt.Errorf("unknown operation: %s", name)
+	}
+	return nil, nil, nil
+}
+
+func (f *BulkFind) updateModel(update interface{}, multi bool) mongo.WriteModel {
+	if multi {
+		model := mongo.NewUpdateManyModel().SetFilter(f.Filter).SetUpdate(update).SetUpsert(f.Upsert)
+		if f.ArrayFilters != nil {
+			model.SetArrayFilters(*f.ArrayFilters)
+		}
+		if f.Collation != nil {
+			model.SetCollation(f.Collation)
+		}
+		if f.Hint != nil {
+			model.SetHint(f.Hint)
+		}
+		return model
+	}
+	model := mongo.NewUpdateOneModel().SetFilter(f.Filter).SetUpdate(update).SetUpsert(f.Upsert)
+	if f.ArrayFilters != nil {
+		model.SetArrayFilters(*f.ArrayFilters)
+	}
+	if f.Collation != nil {
+		model.SetCollation(f.Collation)
+	}
+	if f.Hint != nil {
+		model.SetHint(f.Hint)
+	}
+	return model
+}
+
+func (f *BulkFind) replaceModel(replacement interface{}) mongo.WriteModel {
+	model := mongo.NewReplaceOneModel().SetFilter(f.Filter).SetReplacement(replacement).SetUpsert(f.Upsert)
+	if f.Collation != nil {
+		model.SetCollation(f.Collation)
+	}
+	if f.Hint != nil {
+		model.SetHint(f.Hint)
+	}
+	return model
+}
+
+func (f *BulkFind) deleteModel(multi bool) mongo.WriteModel {
+	if multi {
+		model := mongo.NewDeleteManyModel().SetFilter(f.Filter)
+		if f.Collation != nil {
+			model.SetCollation(f.Collation)
+		}
+		if f.Hint != nil {
+			model.SetHint(f.Hint)
+		}
+		return model
+	}
+	model := mongo.NewDeleteOneModel().SetFilter(f.Filter)
+	if f.Collation != nil {
+		model.SetCollation(f.Collation)
+	}
+	if f.Hint != nil {
+		model.SetHint(f.Hint)
+	}
+	return model
+}
+
+// failedWrites reports whether the operation at 0-based index i failed, or
+// was never run as it comes after the first failure of an ordered write
+func failedWrites(err error, ordered bool) func(i int64) bool {
+	failed := map[int64]bool{}
+	first := int64(-1)
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) {
+		for _, we := range bulkErr.WriteErrors {
+			i := int64(we.Index)
+			failed[i] = true
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	return func(i int64) bool {
+		return failed[i] || (ordered && first >= 0 && i > first)
+	}
+}
+
+// succeededIDs keys the ids by 1-based operation index, the ids of failed
+// writes are dropped
+func succeededIDs(ids map[int64]interface{}, err error, ordered bool) map[int64]interface{} {
+	failed := failedWrites(err, ordered)
+	res := make(map[int64]interface{}, len(ids))
+	for i, id := range ids {
+		if !failed(i) {
+			res[i+1] = id
+		}
+	}
+	return res
+}
+
+func newBulkWriteResult(res *mongo.BulkWriteResult, insertedIDs map[int64]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"insertedCount": res.InsertedCount,
+		"insertedIds":   insertedIDs,
+		"matchedCount":  res.MatchedCount,
+		"modifiedCount": res.ModifiedCount,
+		"deletedCount":  res.DeletedCount,
+		"upsertedCount": res.UpsertedCount,
+		"upsertedIds":   succeededIDs(res.UpsertedIDs, nil, false),
+	}
+}
+
+// bulkWrite executes the write models, the partial result is returned along
+// with the error when some of the operations failed
+func bulkWrite(L *lua.LState, coll *Collection, sess *Session, models []mongo.WriteModel,
+	insertedIDs map[int64]interface{}, opts *options.BulkWriteOptions) int {
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	ordered := opts.Ordered == nil || *opts.Ordered
+	res, err := coll.Collection.BulkWrite(ctx, models, opts)
+	if err != nil {
+		if _, ok := err.(mongo.BulkWriteException); ok && res != nil {
+			L.Push(bsonutil.ToLuaValue(L, newBulkWriteResult(res, succeededIDs(insertedIDs, err, ordered))))
+		} else {
+			L.Push(lua.LNil)
+		}
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newBulkWriteResult(res, succeededIDs(insertedIDs, nil, ordered))))
+	return 1
+}
+
+func collectionBulkWriteMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	ops, ok := bsonutil.CastBSON(L, 2).([]interface{})
+	if !ok {
+		L.ArgError(2, "operations array expected")
+		return 0
+	}
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := bulkWriteOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	models := make([]mongo.WriteModel, 0, len(ops))
+	insertedIDs := map[int64]interface{}{}
+	for i, op := range ops {
+		model, id, err := writeModel(op)
+		if err != nil {
+			L.ArgError(2, err.Error())
+			return 0
+		}
+		if id != nil {
+			insertedIDs[int64(i)] = id
+		}
+		models = append(models, model)
+	}
+
+	return bulkWrite(L, coll, sess, models, insertedIDs, opts)
+}
+
+func collectionInitializeOrderedBulkOpMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	pushBulk(L, coll, true)
+	return 1
+}
+
+func collectionInitializeUnorderedBulkOpMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	pushBulk(L, coll, false)
+	return 1
+}
+
+func bulkExecuteMethod(L *lua.LState) int {
+	bulk := checkBulk(L)
+
+	rawOpts, sess := checkOptions(L, 2)
+	opts, err := bulkWriteOptions(rawOpts)
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	opts.SetOrdered(bulk.Ordered)
+
+	bulk.executed = true
+	return bulkWrite(L, bulk.Collection, sess, bulk.Models, bulk.InsertedIDs, opts)
+}
+
+func bulkFindMethod(L *lua.LState) int {
+	_ = checkBulk(L)
+	filter := bsonutil.CastBSON(L, 2)
+
+	ud := L.NewUserData()
+	ud.Value = &BulkFind{
+		Bulk:   L.CheckUserData(1),
+		Filter: filter,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(BULKFIND_TYPENAME))
+	L.Push(ud)
+	return 1
+}
+
+func bulkInsertMethod(L *lua.LState) int {
+	bulk := checkBulk(L)
+
+	doc, id := ensureID(bsonutil.CastBSON(L, 2))
+	bulk.InsertedIDs[int64(len(bulk.Models))] = id
+	bulk.Models = append(bulk.Models, mongo.NewInsertOneModel().SetDocument(doc))
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+func bulkFindArrayFiltersMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	af, err := toArrayFilters(bsonutil.CastBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	f.ArrayFilters = &af
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+func bulkFindCollationMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	collation, err := toCollation(bsonutil.CastBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	f.Collation = collation
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+func bulkFindHintMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	if L.Get(2).Type() == lua.LTString {
+		f.Hint = L.ToString(2)
+	} else {
+		f.Hint = bsonutil.CastBSON(L, 2)
+	}
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+func bulkFindUpsertMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	f.Upsert = true
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+// addModel appends the model to the bulk and returns the bulk for chaining
+func (f *BulkFind) addModel(L *lua.LState, model mongo.WriteModel) int {
+	bulk := f.Bulk.Value.(*Bulk)
+	if bulk.executed {
+		L.ArgError(1, "bulk operations already executed")
+		return 0
+	}
+	bulk.Models = append(bulk.Models, model)
+
+	L.Push(f.Bulk)
+	return 1
+}
+
+func bulkFindDeleteMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	return f.addModel(L, f.deleteModel(true))
+}
+
+func bulkFindDeleteOneMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	return f.addModel(L, f.deleteModel(false))
+}
+
+func bulkFindReplaceOneMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	return f.addModel(L, f.replaceModel(bsonutil.CastBSON(L, 2)))
+}
+
+func bulkFindUpdateMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	return f.addModel(L, f.updateModel(bsonutil.CastBSON(L, 2), true))
+}
+
+func bulkFindUpdateOneMethod(L *lua.LState) int {
+	f := checkBulkFind(L)
+
+	return f.addModel(L, f.updateModel(bsonutil.CastBSON(L, 2), false))
+}
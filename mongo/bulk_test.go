@@ -0,0 +1,79 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestBulkWrite(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		local res, err = mcoll:bulkWrite({
+		  {insertOne = {document = {_id = 1, a = 1}}},
+		  {insertOne = {document = {_id = 2, a = 2}}},
+		  {updateOne = {filter = {_id = 1}, update = {["$set"] = {b = 1}}}},
+		  {updateMany = {filter = {a = {["$gt"] = 0}}, update = {["$inc"] = {c = 1}}}},
+		  {replaceOne = {filter = {_id = 3}, replacement = {a = 3}, upsert = true}},
+		  {deleteOne = {filter = {_id = 2}}},
+		}, {ordered = false});
+		local res2, err2 = mcoll:bulkWrite({
+		  {insertOne = {document = {_id = 1}}},
+		  {insertOne = {document = {_id = 4}}},
+		}, {ordered = false});
+
+		local bulk = mcoll:initializeUnorderedBulkOp();
+		bulk:insert({a = 5});
+		bulk:find({a = 5}):upsert():updateOne({["$set"] = {b = 5}});
+		bulk:find({_id = 4}):removeOne();
+		local res3, err3 = bulk:execute();
+
+		mcoll:remove({});
+		mongoClient:disconnect();
+		local ids = {res.insertedIds[1], res.insertedIds[2], res.upsertedIds[5], res2.insertedIds[2]};
+		res.insertedIds = nil;
+		res.upsertedIds = nil;
+		return res, err, res2, err2.writeErrors[1].index, err2:isDuplicateKey(), res3, err3, ids, res2.insertedIds[1]
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(9, L.GetTop())
+	assert.Equal(map[string]interface{}{
+		"insertedCount": 2,
+		"matchedCount":  3,
+		"modifiedCount": 3,
+		"deletedCount":  1,
+		"upsertedCount": 1,
+	}, bsonutil.GetValue(L, 1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal([]interface{}{1, 2, 3, 4}, bsonutil.GetValue(L, 8))
+	// the failed insert has no id
+	assert.Equal(lua.LNil, L.Get(9))
+	assert.Equal(1, bsonutil.GetValue(L, 3).(map[string]interface{})["insertedCount"])
+	assert.Equal(lua.LNumber(1), L.Get(4))
+	assert.Equal(lua.LTrue, L.Get(5))
+	res3 := bsonutil.GetValue(L, 6).(map[string]interface{})
+	assert.Equal(1, res3["insertedCount"])
+	assert.Equal(1, res3["matchedCount"])
+	assert.Equal(1, res3["deletedCount"])
+	assert.Equal(lua.LNil, L.Get(7))
+}
@@ -0,0 +1,264 @@
+package gluamongo_mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	CHANGESTREAM_TYPENAME = "mongo{changestream}"
+)
+
+// ChangeStream mongo
+type ChangeStream struct {
+	Client       *Client
+	ChangeStream *mongo.ChangeStream
+	Session      *Session
+	// MaxAwaitTime bounds the wait of next, zero waits for the next event
+	MaxAwaitTime time.Duration
+}
+
+var changeStreamMethods = map[string]lua.LGFunction{
+	"close":       changeStreamCloseMethod,
+	"next":        changeStreamNextMethod,
+	"resumeToken": changeStreamResumeTokenMethod,
+	"tryNext":     changeStreamTryNextMethod,
+}
+
+func pushChangeStream(L *lua.LState, client *Client, sess *Session, cs *mongo.ChangeStream, maxAwaitTime time.Duration) {
+	ud := L.NewUserData()
+	ud.Value = &ChangeStream{
+		Client:       client,
+		ChangeStream: cs,
+		Session:      sess,
+		MaxAwaitTime: maxAwaitTime,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(CHANGESTREAM_TYPENAME))
+	L.Push(ud)
+}
+
+func checkChangeStream(L *lua.LState) *ChangeStream {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*ChangeStream); ok {
+		return v
+	}
+	L.ArgError(1, "mongo change stream expected")
+	return nil
+}
+
+// checkPipeline gets aggregation pipeline at idx, nil or {} as empty pipeline
+func checkPipeline(L *lua.LState, idx int) interface{} {
+	pipeline := bsonutil.ToBSON(L, idx)
+	if pipeline == nil {
+		return bson.A{}
+	}
+	if m, ok := pipeline.(map[string]interface{}); ok && len(m) == 0 {
+		return bson.A{}
+	}
+	return pipeline
+}
+
+func changeStreamOptions(opts interface{}) (*options.ChangeStreamOptions, error) {
+	co := options.ChangeStream()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "batchSize":
+			var i int64
+			if i, err = toNumber(v); err == nil {
+				co.SetBatchSize(int32(i))
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				co.SetCollation(*c)
+			}
+		case "fullDocument":
+			var str string
+			if str, err = toString(v); err == nil {
+				co.SetFullDocument(options.FullDocument(str))
+			}
+		case "maxAwaitTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				co.SetMaxAwaitTime(d)
+			}
+		case "resumeAfter":
+			co.SetResumeAfter(v)
+		case "startAfter":
+			co.SetStartAfter(v)
+		case "startAtOperationTime":
+			if ts, ok := v.(primitive.Timestamp); ok {
+				co.SetStartAtOperationTime(&ts)
+			} else {
+				err = fmt.Errorf("bson timestamp expected")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return co, nil
+}
+
+// watch opens change stream with pipeline at 2 and options at 3
+func watch(L *lua.LState, client *Client, fn func(ctx context.Context, pipeline interface{}, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error)) int {
+	pipeline := checkPipeline(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := changeStreamOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := client.SessionContext(sess)
+	defer cancel()
+
+	cs, err := fn(ctx, pipeline, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	var maxAwaitTime time.Duration
+	if opts.MaxAwaitTime != nil {
+		maxAwaitTime = *opts.MaxAwaitTime
+	}
+	pushChangeStream(L, client, sess, cs, maxAwaitTime)
+	return 1
+}
+
+// next waits for the next event. In blocking mode the stream is polled until
+// an event arrives, or returns nil once maxAwaitTime passes if it is set. The
+// context of a single poll is not cut short as an interrupted getMore would
+// make the change stream unusable.
+func (cs *ChangeStream) next(L *lua.LState, nonBlocking bool, maxAwaitTime time.Duration) (lua.LValue, error) {
+	deadline := time.Now().Add(maxAwaitTime)
+	for {
+		ctx, cancel := cs.Client.SessionContext(cs.Session)
+		ok := cs.ChangeStream.TryNext(ctx)
+		cancel()
+		if ok {
+			return cs.Client.decode(L, cs.ChangeStream.Current)
+		}
+		if err := cs.ChangeStream.Err(); err != nil {
+			return lua.LNil, err
+		}
+		if nonBlocking || cs.ChangeStream.ID() == 0 {
+			// no event yet, or the stream is closed
+			return lua.LNil, nil
+		}
+		if maxAwaitTime > 0 && !time.Now().Before(deadline) {
+			return lua.LNil, nil
+		}
+	}
+}
+
+func changeStreamCloseMethod(L *lua.LState) int {
+	cs := checkChangeStream(L)
+
+	ctx, cancel := cs.Client.Context()
+	defer cancel()
+
+	err := cs.ChangeStream.Close(ctx)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// changeStreamNextMethod waits for the next event, the maxAwaitTimeMS option
+// overrides the one of watch, nil is returned when it passes without events
+func changeStreamNextMethod(L *lua.LState) int {
+	cs := checkChangeStream(L)
+
+	maxAwaitTime := cs.MaxAwaitTime
+	m, err := optionsMap(bsonutil.ToBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	if v, ok := m["maxAwaitTimeMS"]; ok {
+		if maxAwaitTime, err = toDuration(v); err != nil {
+			L.ArgError(2, fmt.Sprintf("invalid maxAwaitTimeMS option: %v", err))
+			return 0
+		}
+	}
+
+	event, err := cs.next(L, false, maxAwaitTime)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(event)
+	return 1
+}
+
+func changeStreamResumeTokenMethod(L *lua.LState) int {
+	cs := checkChangeStream(L)
+
+	token := cs.ChangeStream.ResumeToken()
+	if token == nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	result, err := cs.Client.decode(L, token)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(result)
+	return 1
+}
+
+func changeStreamTryNextMethod(L *lua.LState) int {
+	cs := checkChangeStream(L)
+
+	event, err := cs.next(L, true, 0)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(event)
+	return 1
+}
+
+// changeStreamCallMethod makes change stream usable as a generic-for iterator:
+// for event in stream do ... end
+// the loop ends once maxAwaitTimeMS of watch passes without events, only
+// server errors are raised
+func changeStreamCallMethod(L *lua.LState) int {
+	cs := checkChangeStream(L)
+
+	event, err := cs.next(L, false, cs.MaxAwaitTime)
+	if err != nil {
+		L.Error(LError(L, err), 1)
+		return 0
+	}
+
+	L.Push(event)
+	return 1
+}
@@ -0,0 +1,59 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestCollectionWatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local stream, err = mcoll:watch({{["$match"] = {operationType = 'insert'}}}, {fullDocument = 'updateLookup', maxAwaitTimeMS = 200});
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:insert({a = 10});
+		local event, err = stream:next({maxAwaitTimeMS = 5000});
+		local token = stream:resumeToken();
+		local none, err2 = stream:next({maxAwaitTimeMS = 500});
+		mcoll:insert({a = 11});
+		local n = 0;
+		for change in stream do
+		  n = n + 1;
+		end
+		stream:close();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return event.operationType, event.fullDocument.a, err, token ~= nil, none, err2, n
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(7, L.GetTop())
+	assert.Equal("insert", L.ToString(1))
+	assert.Equal(lua.LNumber(10), L.Get(2))
+	assert.Equal(lua.LNil, L.Get(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+	// no event within maxAwaitTimeMS is not an error
+	assert.Equal(lua.LNil, L.Get(5))
+	assert.Equal(lua.LNil, L.Get(6))
+	// the loop ends once the stream is quiet
+	assert.Equal(lua.LNumber(1), L.Get(7))
+}
@@ -2,6 +2,7 @@ package gluamongo_mongo
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/tengattack/gluamongo/bsonutil"
@@ -17,14 +18,58 @@ const (
 
 // Client mongo
 type Client struct {
-	Client  *mongo.Client
-	Timeout time.Duration
+	Client        *mongo.Client
+	Timeout       time.Duration
+	DecodeOptions bsonutil.DecodeOptions
 }
 
 func (client *Client) Context() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), client.Timeout)
 }
 
+// SessionContext returns the context bound to session, if session is not nil
+func (client *Client) SessionContext(sess *Session) (context.Context, context.CancelFunc) {
+	ctx, cancel := client.Context()
+	if sess != nil {
+		return mongo.NewSessionContext(ctx, sess.Session), cancel
+	}
+	return ctx, cancel
+}
+
+// toLuaValue converts bson value with the decode options
+func (client *Client) toLuaValue(L *lua.LState, v interface{}) lua.LValue {
+	return bsonutil.ToLuaValueWith(L, v, &client.DecodeOptions)
+}
+
+// decode converts raw document with the decode options, documents are decoded
+// as bson.D so that the key order can be kept
+func (client *Client) decode(L *lua.LState, raw bson.Raw) (lua.LValue, error) {
+	var doc bson.D
+	err := bson.Unmarshal(raw, &doc)
+	if err != nil {
+		return lua.LNil, err
+	}
+	return client.toLuaValue(L, doc), nil
+}
+
+// decodeAll converts the remaining documents of cursor to array
+func (client *Client) decodeAll(ctx context.Context, L *lua.LState, cur *mongo.Cursor) (lua.LValue, error) {
+	defer cur.Close(ctx)
+
+	tb := L.NewTable()
+	for cur.Next(ctx) {
+		doc, err := client.decode(L, cur.Current)
+		if err != nil {
+			return lua.LNil, err
+		}
+		tb.Append(doc)
+	}
+	if err := cur.Err(); err != nil {
+		return lua.LNil, err
+	}
+	return tb, nil
+}
+
 func newClient(L *lua.LState) int {
 	timeout := 10 * time.Second
 	ud := L.NewUserData()
@@ -38,13 +83,17 @@ func newClient(L *lua.LState) int {
 }
 
 var clientMethods = map[string]lua.LGFunction{
-	"set_timeout": clientSetTimeoutMethod,
-	"connect":     clientConnectMethod,
-	"disconnect":  clientDisconnectMethod,
+	"set_decode_options": clientSetDecodeOptionsMethod,
+	"set_timeout":        clientSetTimeoutMethod,
+	"connect":            clientConnectMethod,
+	"disconnect":         clientDisconnectMethod,
 
+	"adminCommand":     clientAdminCommandMethod,
 	"getCollection":    clientGetCollectionMethod,
 	"getDatabase":      clientGetDatabaseMethod,
 	"getDatabaseNames": clientGetDatabaseNamesMethod,
+	"startSession":     clientStartSessionMethod,
+	"watch":            clientWatchMethod,
 }
 
 func checkClient(L *lua.LState) *Client {
@@ -78,14 +127,14 @@ func clientConnectMethod(L *lua.LState) int {
 
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 	err = mongoClient.Ping(ctx, nil)
 	if err != nil {
 		_ = mongoClient.Disconnect(ctx)
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 	client.Client = mongoClient
@@ -109,7 +158,7 @@ func clientDisconnectMethod(L *lua.LState) int {
 	client.Client = nil
 	if err != nil {
 		L.Push(lua.LBool(false))
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
@@ -127,6 +176,39 @@ func clientSetTimeoutMethod(L *lua.LState) int {
 	return 1
 }
 
+// clientSetDecodeOptionsMethod sets how documents are returned, e.g.
+// {ordered = true} returns bson{doc} keeping the key order, {int64 = true}
+// returns bson{int64} for the int64 values a lua number cannot hold and
+// {null = true} returns mongo.Null for null values
+func clientSetDecodeOptionsMethod(L *lua.LState) int {
+	client := checkClient(L)
+
+	m, err := optionsMap(bsonutil.ToBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	opts := client.DecodeOptions
+	for key, v := range m {
+		switch key {
+		case "ordered":
+			opts.Ordered, err = toBool(v)
+		case "int64":
+			opts.Int64, err = toBool(v)
+		case "null":
+			opts.Null, err = toBool(v)
+		}
+		if err != nil {
+			L.ArgError(2, fmt.Sprintf("invalid %s option: %v", key, err))
+			return 0
+		}
+	}
+	client.DecodeOptions = opts
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
 func clientGetCollectionMethod(L *lua.LState) int {
 	client := checkClient(L)
 	dbname := L.ToString(2)
@@ -171,10 +253,38 @@ func clientGetDatabaseNamesMethod(L *lua.LState) int {
 	names, err := client.Client.ListDatabaseNames(ctx, options)
 	if err != nil {
 		L.Push(lua.LBool(false))
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
 	L.Push(bsonutil.ToLuaValue(L, names))
 	return 1
 }
+
+func clientStartSessionMethod(L *lua.LState) int {
+	client := checkClient(L)
+
+	opts, err := sessionOptions(bsonutil.ToBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	sess, err := client.Client.StartSession(opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	pushSession(L, client, sess)
+	return 1
+}
+
+func clientWatchMethod(L *lua.LState) int {
+	client := checkClient(L)
+
+	return watch(L, client, func(ctx context.Context, pipeline interface{}, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return client.Client.Watch(ctx, pipeline, opts)
+	})
+}
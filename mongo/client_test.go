@@ -50,3 +50,190 @@ func TestNewClient(t *testing.T) {
 	assert.NotEmpty(bsonutil.GetValue(L, 5))
 	assert.Equal(lua.LTrue, L.Get(6))
 }
+
+func TestOrderedDecode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert(mongo.Doc({{"_id", 1}, {"z", 1}, {"y", mongo.Doc({{"b", 1}, {"a", 2}})}}));
+		mcoll:createIndex(mongo.Doc({{"z", 1}, {"y", -1}}));
+		mongoClient:set_decode_options({ordered = true});
+		local doc = mcoll:findOne({_id = 1});
+		local keys = {};
+		for k in doc() do
+		  table.insert(keys, k);
+		end
+		for k in doc.y() do
+		  table.insert(keys, k);
+		end
+		local docs = mcoll:find({}, {sort = mongo.Doc({{"z", 1}, {"_id", -1}})}):toArray();
+		local indexes = mcoll:listIndexes();
+		mcoll:dropIndexes();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return table.concat(keys, ','), #docs, indexes[2].name
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(3, L.GetTop())
+	assert.Equal("_id,z,y,b,a", L.ToString(1))
+	assert.Equal(lua.LNumber(1), L.Get(2))
+	assert.Equal("z_1_y_-1", L.ToString(3))
+}
+
+func TestInt64Decode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({_id = 1, a = mongo.Int64("9007199254740993"), b = mongo.Int32(1), c = mongo.Double(2)});
+		local doc1 = mcoll:findOne({_id = 1, b = {["$type"] = "int"}, c = {["$type"] = "double"}});
+		mongoClient:set_decode_options({int64 = true});
+		local doc2 = mcoll:findOne({_id = 1});
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return type(doc1.a), tostring(doc2.a), doc2.b
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(3, L.GetTop())
+	assert.Equal("number", L.ToString(1))
+	assert.Equal("Int64(9007199254740993)", L.ToString(2))
+	assert.Equal(lua.LNumber(1), L.Get(3))
+}
+
+func TestBinaryDecode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		local id = mongo.UUID();
+		mcoll:insert({_id = id, b = mongo.Binary("\0\1\2")});
+		local doc = mcoll:findOne({_id = id});
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return doc._id == id, doc._id:subtype(), doc.b:hex()
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(3, L.GetTop())
+	assert.Equal(lua.LTrue, L.Get(1))
+	assert.Equal(lua.LNumber(4), L.Get(2))
+	assert.Equal("000102", L.ToString(3))
+}
+
+func TestRegexQuery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({_id = 1, name = "ABCdef", re = mongo.Regex("^x", "i")});
+		mcoll:insert({_id = 2, name = "xabc"});
+		local docs = mcoll:find({name = mongo.Regex("^abc", "i")}):toArray();
+		local docs2 = mcoll:find({name = mongo.Regex.fromLuaPattern("^%a+$")}):toArray();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return #docs, docs[1]._id, tostring(docs[1].re), #docs2
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(4, L.GetTop())
+	assert.Equal(lua.LNumber(1), L.Get(1))
+	assert.Equal(lua.LNumber(1), L.Get(2))
+	assert.Equal("/^x/i", L.ToString(3))
+	assert.Equal(lua.LNumber(2), L.Get(4))
+}
+
+func TestNullDecode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({_id = 1, a = mongo.Null});
+		local doc1 = mcoll:findOne({_id = 1});
+		mongoClient:set_decode_options({null = true});
+		local doc2 = mcoll:findOne({_id = 1});
+		mcoll:replaceOne({_id = 1}, doc2);
+		local count = mcoll:count({a = {["$type"] = "null"}});
+		local res = mcoll:updateOne({_id = 1}, {["$set"] = {b = 1}});
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return doc1.a == nil, doc2.a == mongo.Null, doc2.b == nil, count, res.upsertedId == nil, res.matchedCount
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(6, L.GetTop())
+	assert.Equal(lua.LTrue, L.Get(1))
+	assert.Equal(lua.LTrue, L.Get(2))
+	assert.Equal(lua.LTrue, L.Get(3))
+	assert.Equal(lua.LNumber(1), L.Get(4))
+	// result fields that are not set stay nil
+	assert.Equal(lua.LTrue, L.Get(5))
+	assert.Equal(lua.LNumber(1), L.Get(6))
+}
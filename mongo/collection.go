@@ -1,7 +1,9 @@
 package gluamongo_mongo
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/tengattack/gluamongo/bsonutil"
 	lua "github.com/yuin/gopher-lua"
@@ -21,15 +23,40 @@ type Collection struct {
 }
 
 var collectionMethods = map[string]lua.LGFunction{
-	// "drop":                nil,
-	"aggregate": collectionAggregateMethod,
-	"count":     collectionCountMethod,
-	"find":      collectionFindMethod,
-	"findOne":   collectionFindOneMethod,
-	"getName":   collectionGetNameMethod,
-	"insert":    collectionInsertMethod,
-	"remove":    collectionRemoveMethod,
-	"update":    collectionUpdateMethod,
+	"aggregate":                 collectionAggregateMethod,
+	"bulkWrite":                 collectionBulkWriteMethod,
+	"count":                     collectionCountMethod,
+	"createIndex":               collectionCreateIndexMethod,
+	"createIndexes":             collectionCreateIndexesMethod,
+	"cursor":                    collectionFindMethod,
+	"deleteMany":                collectionDeleteManyMethod,
+	"deleteOne":                 collectionDeleteOneMethod,
+	"distinct":                  collectionDistinctMethod,
+	"drop":                      collectionDropMethod,
+	"dropIndex":                 collectionDropIndexMethod,
+	"dropIndexes":               collectionDropIndexesMethod,
+	"estimatedDocumentCount":    collectionEstimatedDocumentCountMethod,
+	"find":                      collectionFindMethod,
+	"findOne":                   collectionFindOneMethod,
+	"findOneAndDelete":          collectionFindOneAndDeleteMethod,
+	"findOneAndReplace":         collectionFindOneAndReplaceMethod,
+	"findOneAndUpdate":          collectionFindOneAndUpdateMethod,
+	"getName":                   collectionGetNameMethod,
+	"hideIndex":                 collectionHideIndexMethod,
+	"initializeOrderedBulkOp":   collectionInitializeOrderedBulkOpMethod,
+	"initializeUnorderedBulkOp": collectionInitializeUnorderedBulkOpMethod,
+	"insert":                    collectionInsertMethod,
+	"insertMany":                collectionInsertManyMethod,
+	"insertOne":                 collectionInsertOneMethod,
+	"listIndexes":               collectionListIndexesMethod,
+	"remove":                    collectionRemoveMethod,
+	"rename":                    collectionRenameMethod,
+	"replaceOne":                collectionReplaceOneMethod,
+	"unhideIndex":               collectionUnhideIndexMethod,
+	"update":                    collectionUpdateMethod,
+	"updateMany":                collectionUpdateManyMethod,
+	"updateOne":                 collectionUpdateOneMethod,
+	"watch":                     collectionWatchMethod,
 }
 
 func pushCollection(L *lua.LState, client *Client, collection *mongo.Collection) {
@@ -51,65 +78,53 @@ func checkCollection(L *lua.LState) *Collection {
 	return nil
 }
 
-func toNumber(v interface{}) (int64, error) {
-	switch i := v.(type) {
-	case int64:
-		return i, nil
-	case int:
-		return int64(i), nil
-	case int32:
-		return int64(i), nil
-	case float64:
-		return int64(i), nil
-	}
-	return 0, fmt.Errorf("unknown value: %v (%T)", v, v)
-}
-
 func collectionFindOptions(opts interface{}) (*options.FindOptions, error) {
 	fo := &options.FindOptions{}
-	if opts == nil {
-		return fo, nil
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
 	}
-	if m, ok := opts.(map[string]interface{}); ok {
-		if v, ok := m["projection"]; ok {
+	for key, v := range m {
+		switch key {
+		case "projection":
 			fo.SetProjection(v)
-		}
-		if v, ok := m["sort"]; ok {
+		case "sort":
 			fo.SetSort(v)
-		}
-		if v, ok := m["skip"]; ok {
-			i, err := toNumber(v)
-			if err != nil {
-				return nil, err
-			}
-			fo.SetSkip(i)
-		}
-		if v, ok := m["limit"]; ok {
-			i, err := toNumber(v)
-			if err != nil {
-				return nil, err
-			}
-			fo.SetLimit(i)
-		}
-	} else if m, ok := opts.(bson.D); ok {
-		for _, v := range m {
-			if v.Key == "projection" {
-				fo.SetProjection(v.Value)
-			} else if v.Key == "sort" {
-				fo.SetSort(v.Value)
-			} else if v.Key == "skip" {
-				i, err := toNumber(v)
-				if err != nil {
-					return nil, err
-				}
+		case "skip":
+			var i int64
+			if i, err = toNumber(v); err == nil {
 				fo.SetSkip(i)
-			} else if v.Key == "limit" {
-				i, err := toNumber(v)
-				if err != nil {
-					return nil, err
-				}
+			}
+		case "limit":
+			var i int64
+			if i, err = toNumber(v); err == nil {
 				fo.SetLimit(i)
 			}
+		case "batchSize":
+			var i int64
+			if i, err = toNumber(v); err == nil {
+				fo.SetBatchSize(int32(i))
+			}
+		case "hint":
+			fo.SetHint(v)
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				fo.SetCollation(c)
+			}
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				fo.SetMaxTime(d)
+			}
+		case "comment":
+			var str string
+			if str, err = toString(v); err == nil {
+				fo.SetComment(str)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
 		}
 	}
 	return fo, nil
@@ -118,35 +133,15 @@ func collectionFindOptions(opts interface{}) (*options.FindOptions, error) {
 func collectionAggregateMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
-	query := bsonutil.CastBSON(L, 2)
-
-	ctx, cancel := coll.Client.Context()
-	defer cancel()
-
-	cur, err := coll.Collection.Aggregate(ctx, query)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-
-	var results []bson.M
-	err = cur.All(ctx, &results)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-
-	L.Push(bsonutil.ToLuaValue(L, results))
-	return 1
+	return aggregate(L, coll.Client, coll.Collection)
 }
 
 func collectionCountMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
 	query := bsonutil.CastBSON(L, 2)
-	opts, err := collectionFindOptions(bsonutil.ToBSON(L, 3))
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := collectionFindOptions(rawOpts)
 	if err != nil {
 		L.ArgError(3, err.Error())
 		return 0
@@ -161,13 +156,13 @@ func collectionCountMethod(L *lua.LState) int {
 		}
 	}
 
-	ctx, cancel := coll.Client.Context()
+	ctx, cancel := coll.Client.SessionContext(sess)
 	defer cancel()
 
 	count, err := coll.Collection.CountDocuments(ctx, query, countOptions)
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
@@ -175,35 +170,122 @@ func collectionCountMethod(L *lua.LState) int {
 	return 1
 }
 
-func collectionFindMethod(L *lua.LState) int {
+func collectionDistinctMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
-	query := bsonutil.CastBSON(L, 2)
-	opts, err := collectionFindOptions(bsonutil.ToBSON(L, 3))
+	field := L.CheckString(2)
+	var query interface{} = map[string]interface{}{}
+	if L.Get(3) != lua.LNil {
+		query = bsonutil.CastBSON(L, 3)
+	}
+	rawOpts, sess := checkOptions(L, 4)
+	m, err := optionsMap(rawOpts)
 	if err != nil {
-		L.ArgError(3, err.Error())
+		L.ArgError(4, err.Error())
 		return 0
 	}
+	opts := options.Distinct()
+	if v, ok := m["collation"]; ok {
+		c, err := toCollation(v)
+		if err != nil {
+			L.ArgError(4, fmt.Sprintf("invalid collation option: %v", err))
+			return 0
+		}
+		opts.SetCollation(c)
+	}
+	if v, ok := m["maxTimeMS"]; ok {
+		d, err := toDuration(v)
+		if err != nil {
+			L.ArgError(4, fmt.Sprintf("invalid maxTimeMS option: %v", err))
+			return 0
+		}
+		opts.SetMaxTime(d)
+	}
 
-	ctx, cancel := coll.Client.Context()
+	ctx, cancel := coll.Client.SessionContext(sess)
 	defer cancel()
 
-	cur, err := coll.Collection.Find(ctx, query, opts)
+	values, err := coll.Collection.Distinct(ctx, field, query, opts)
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
-	var results []bson.M
-	err = cur.All(ctx, &results)
+	// as a decoded array, so that null values follow the decode options
+	L.Push(coll.Client.toLuaValue(L, bson.A(values)))
+	return 1
+}
+
+func collectionDropMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+	_, sess := checkOptions(L, 2)
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	err := coll.Collection.Drop(ctx)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// collectionEstimatedDocumentCountMethod counts with collection metadata
+func collectionEstimatedDocumentCountMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	rawOpts, sess := checkOptions(L, 2)
+	m, err := optionsMap(rawOpts)
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	opts := options.EstimatedDocumentCount()
+	if v, ok := m["maxTimeMS"]; ok {
+		d, err := toDuration(v)
+		if err != nil {
+			L.ArgError(2, fmt.Sprintf("invalid maxTimeMS option: %v", err))
+			return 0
+		}
+		opts.SetMaxTime(d)
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	count, err := coll.Collection.EstimatedDocumentCount(ctx, opts)
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
-	L.Push(bsonutil.ToLuaValue(L, results))
+	L.Push(lua.LNumber(count))
+	return 1
+}
+
+// collectionFindMethod returns a lazy cursor, executed on first iteration
+func collectionFindMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := collectionFindOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	pushCursor(L, coll.Client, sess, &findQuery{
+		collection: coll.Collection,
+		filter:     query,
+		options:    opts,
+	})
 	return 1
 }
 
@@ -211,7 +293,8 @@ func collectionFindOneMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
 	query := bsonutil.CastBSON(L, 2)
-	opts, err := collectionFindOptions(bsonutil.ToBSON(L, 3))
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := collectionFindOptions(rawOpts)
 	if err != nil {
 		L.ArgError(3, err.Error())
 		return 0
@@ -227,33 +310,24 @@ func collectionFindOneMethod(L *lua.LState) int {
 		if opts.Skip != nil {
 			foOptions.SetSkip(*opts.Skip)
 		}
-	}
-
-	ctx, cancel := coll.Client.Context()
-	defer cancel()
-
-	res := coll.Collection.FindOne(ctx, query, foOptions)
-	err = res.Err()
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			L.Push(lua.LNil)
-			return 1
+		if opts.Hint != nil {
+			foOptions.SetHint(opts.Hint)
+		}
+		if opts.Collation != nil {
+			foOptions.SetCollation(opts.Collation)
+		}
+		if opts.MaxTime != nil {
+			foOptions.SetMaxTime(*opts.MaxTime)
+		}
+		if opts.Comment != nil {
+			foOptions.SetComment(*opts.Comment)
 		}
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
 	}
 
-	var result bson.M
-	err = res.Decode(&result)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
 
-	L.Push(bsonutil.ToLuaValue(L, result))
-	return 1
+	return pushSingleResult(L, coll.Client, coll.Collection.FindOne(ctx, query, foOptions))
 }
 
 func collectionGetNameMethod(L *lua.LState) int {
@@ -272,15 +346,16 @@ func collectionInsertMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
 	doc := bsonutil.CastBSON(L, 2)
+	_, sess := checkOptions(L, 3)
 
-	ctx, cancel := coll.Client.Context()
+	ctx, cancel := coll.Client.SessionContext(sess)
 	defer cancel()
 
 	if arr, ok := doc.([]interface{}); ok {
 		res, err := coll.Collection.InsertMany(ctx, arr)
 		if err != nil {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
+			L.Push(LError(L, err))
 			return 2
 		}
 		L.Push(bsonutil.ToLuaValue(L, newInsertResult(len(res.InsertedIDs))))
@@ -289,7 +364,7 @@ func collectionInsertMethod(L *lua.LState) int {
 		_, err := coll.Collection.InsertOne(ctx, doc)
 		if err != nil {
 			L.Push(lua.LNil)
-			L.Push(lua.LString(err.Error()))
+			L.Push(LError(L, err))
 			return 2
 		}
 		L.Push(bsonutil.ToLuaValue(L, newInsertResult(1)))
@@ -306,11 +381,13 @@ func collectionRemoveMethod(L *lua.LState) int {
 
 	query := bsonutil.CastBSON(L, 2)
 	var justOne bool
+	var sess *Session
 	lv := L.Get(3)
 	if lv.Type() == lua.LTBool {
 		justOne = lua.LVAsBool(lv)
 	} else {
-		options := bsonutil.ToBSON(L, 3)
+		var options interface{}
+		options, sess = checkOptions(L, 3)
 		if options != nil {
 			// TODO: bson.D
 			if v, ok := options.(map[string]interface{}); ok {
@@ -326,7 +403,7 @@ func collectionRemoveMethod(L *lua.LState) int {
 		}
 	}
 
-	ctx, cancel := coll.Client.Context()
+	ctx, cancel := coll.Client.SessionContext(sess)
 	defer cancel()
 
 	var res *mongo.DeleteResult
@@ -338,7 +415,7 @@ func collectionRemoveMethod(L *lua.LState) int {
 	}
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 	L.Push(bsonutil.ToLuaValue(L, newRemoveResult(int(res.DeletedCount))))
@@ -353,41 +430,52 @@ func newUpdateResult(res *mongo.UpdateResult) map[string]int {
 	}
 }
 
+// collectionRenameMethod renames the collection in the same database, the
+// existing target collection is dropped when dropTarget is true
+func collectionRenameMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+	newName := L.CheckString(2)
+	dropTarget := L.OptBool(3, false)
+
+	db := coll.Collection.Database()
+	n := runCommand(L, coll.Client, coll.Client.Client.Database("admin"), nil, bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + coll.Collection.Name()},
+		{Key: "to", Value: db.Name() + "." + newName},
+		{Key: "dropTarget", Value: dropTarget},
+	}, nil)
+	if n == 1 {
+		// the collection object follows the renamed collection
+		coll.Collection = db.Collection(newName)
+	}
+	return n
+}
+
+// collectionUpdateMethod updates with filter at 2, update document or
+// aggregation pipeline at 3 and options at 4
 func collectionUpdateMethod(L *lua.LState) int {
 	coll := checkCollection(L)
 
 	query := bsonutil.CastBSON(L, 2)
 	document := bsonutil.CastBSON(L, 3)
-	opts := &options.UpdateOptions{}
-
+	rawOpts, sess := checkOptions(L, 4)
+	opts, err := updateOptions(rawOpts)
+	if err != nil {
+		L.ArgError(4, err.Error())
+		return 0
+	}
+	m, _ := optionsMap(rawOpts)
 	var multi bool
-	options := bsonutil.ToBSON(L, 3)
-	if options != nil {
-		if v, ok := options.(map[string]interface{}); ok {
-			if v2, ok2 := v["multi"]; ok2 {
-				if multiVal, ok3 := v2.(bool); ok3 {
-					multi = multiVal
-				} else {
-					L.ArgError(3, "invalid multi option")
-					return 0
-				}
-			}
-			if v2, ok2 := v["upsert"]; ok2 {
-				if upsertVal, ok3 := v2.(bool); ok3 {
-					opts.SetUpsert(upsertVal)
-				} else {
-					L.ArgError(3, "invalid upsert option")
-					return 0
-				}
-			}
+	if v, ok := m["multi"]; ok {
+		if multi, err = toBool(v); err != nil {
+			L.ArgError(4, "invalid multi option")
+			return 0
 		}
 	}
 
-	ctx, cancel := coll.Client.Context()
+	ctx, cancel := coll.Client.SessionContext(sess)
 	defer cancel()
 
 	var res *mongo.UpdateResult
-	var err error
 	if multi {
 		res, err = coll.Collection.UpdateMany(ctx, query, document, opts)
 	} else {
@@ -395,9 +483,17 @@ func collectionUpdateMethod(L *lua.LState) int {
 	}
 	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 	L.Push(bsonutil.ToLuaValue(L, newUpdateResult(res)))
 	return 1
 }
+
+func collectionWatchMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	return watch(L, coll.Client, func(ctx context.Context, pipeline interface{}, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return coll.Collection.Watch(ctx, pipeline, opts)
+	})
+}
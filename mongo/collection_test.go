@@ -137,7 +137,7 @@ func TestInsertFindRemove(t *testing.T) {
 		end
 		mcoll:remove({}); -- remove all
 		local res, err = mcoll:insert({{a = 1, b = 2, c = mongo.Null, dt = mongo.DateTime(1620279393253), ts = mongo.Timestamp()}, {a = 1, b = 1, dt = mongo.DateTime(os.time() * 1000)}});
-		local res2, err2 = mcoll:find({a = 1}, {sort = {b = 1}});
+		local res2, err2 = mcoll:find({a = 1}, {sort = {b = 1}}):toArray();
 		local res3, err3 = mcoll:remove({a = 1});
 		local res4, err4 = mcoll:findOne({a = 1});
 		mongoClient:disconnect();
@@ -165,3 +165,90 @@ func TestInsertFindRemove(t *testing.T) {
 	// 4 keys: _id, a, b, dt
 	assert.Len(v.([]interface{})[0], 4)
 }
+
+func TestUpdateOptions(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 1, b = 1, arr = {1, 2, 3}}, {a = 1, b = 2, arr = {1, 5}}});
+		local res, err = mcoll:update({a = 1}, {{["$set"] = {c = {["$add"] = {"$a", "$b"}}}}}, {multi = true});
+		local res2, err2 = mcoll:update({b = 1}, {["$set"] = {["arr.$[x]"] = 0}}, {arrayFilters = {{x = {["$gte"] = 2}}}});
+		local res3, err3 = mcoll:update({a = 2}, {["$set"] = {b = 3}}, '{"upsert": true, "hint": {"_id": 1}}');
+		local res4, err4 = mcoll:update({["$expr"] = {["$eq"] = {"$b", "$$v"}}}, {["$set"] = {d = 1}}, {let = {v = 2}, comment = 'update', multi = true});
+		local doc = mcoll:findOne({b = 1});
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return res.nModified, err, res2.nModified, err2, res3.nUpserted, err3, doc.c, doc.arr[2] + doc.arr[3],
+		  res4.nModified, err4
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(10, L.GetTop())
+	assert.Equal(lua.LNumber(2), L.Get(1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(1), L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNumber(1), L.Get(5))
+	assert.Equal(lua.LNil, L.Get(6))
+	assert.Equal(lua.LNumber(2), L.Get(7))
+	assert.Equal(lua.LNumber(0), L.Get(8))
+	assert.Equal(lua.LNumber(1), L.Get(9))
+	assert.Equal(lua.LNil, L.Get(10))
+}
+
+func TestDistinctEstimatedDocumentCount(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 'x', b = 1}, {a = 'X', b = 2}, {a = 'y', b = 2}});
+		local values, err = mcoll:distinct('a', {b = 2});
+		local values2, err2 = mcoll:distinct('a', nil, {collation = {locale = 'en', strength = 2}, maxTimeMS = 1000});
+		local count, err3 = mcoll:estimatedDocumentCount({maxTimeMS = 1000});
+		local session = mongoClient:startSession();
+		local count2, err4 = mcoll:estimatedDocumentCount({session = session});
+		session:endSession();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		table.sort(values);
+		return values, err, #values2, err2, count, err3, count2, err4
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(8, L.GetTop())
+	assert.Equal([]interface{}{"X", "y"}, bsonutil.GetValue(L, 1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(2), L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNumber(3), L.Get(5))
+	assert.Equal(lua.LNil, L.Get(6))
+	assert.Equal(lua.LNumber(3), L.Get(7))
+	assert.Equal(lua.LNil, L.Get(8))
+}
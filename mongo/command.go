@@ -0,0 +1,154 @@
+package gluamongo_mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type commandQuery struct {
+	database *mongo.Database
+	command  interface{}
+	options  *options.RunCmdOptions
+}
+
+func (q *commandQuery) open(ctx context.Context) (*mongo.Cursor, error) {
+	return q.database.RunCommandCursor(ctx, q.command, q.options)
+}
+
+// setBatchSize sets cursor.batchSize in the command, find takes batchSize
+// instead
+func (q *commandQuery) setBatchSize(n int32) {
+	cmd, ok := q.command.(bson.D)
+	if !ok || len(cmd) == 0 {
+		return
+	}
+	var key string
+	var value interface{}
+	if cmd[0].Key == "find" {
+		key, value = "batchSize", n
+	} else {
+		key, value = "cursor", bson.D{{Key: "batchSize", Value: n}}
+	}
+	for i, e := range cmd {
+		if e.Key == key {
+			cmd[i].Value = value
+			return
+		}
+	}
+	q.command = append(cmd, bson.E{Key: key, Value: value})
+}
+
+func runCmdOptions(opts interface{}) (*options.RunCmdOptions, error) {
+	ro := options.RunCmd()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "readPreference":
+			var rp *readpref.ReadPref
+			if rp, err = toReadPref(v); err == nil {
+				ro.SetReadPreference(rp)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return ro, nil
+}
+
+// runCommand runs command on the database and returns the raw reply
+func runCommand(L *lua.LState, client *Client, db *mongo.Database, sess *Session, cmd interface{}, opts *options.RunCmdOptions) int {
+	ctx, cancel := client.SessionContext(sess)
+	defer cancel()
+
+	raw, err := db.RunCommand(ctx, cmd, opts).DecodeBytes()
+	if err == nil {
+		var result lua.LValue
+		if result, err = client.decode(L, raw); err == nil {
+			L.Push(result)
+			return 1
+		}
+	}
+	L.Push(lua.LNil)
+	L.Push(LError(L, err))
+	return 2
+}
+
+// emptyDocuments converts the nested empty tables of command to empty
+// documents, e.g. filter = {}, except for pipeline which is an array
+func emptyDocuments(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case bson.D:
+		for i, e := range vv {
+			if a, ok := e.Value.([]interface{}); ok && len(a) == 0 && e.Key == "pipeline" {
+				continue
+			}
+			vv[i].Value = emptyDocuments(e.Value)
+		}
+	case []interface{}:
+		if len(vv) == 0 {
+			return bson.D{}
+		}
+		for i, e := range vv {
+			vv[i] = emptyDocuments(e)
+		}
+	}
+	return v
+}
+
+// checkCommand gets command at 2 and options at 3, the command keeps the key
+// order as the command name must come first. Nested empty tables are empty
+// documents.
+func checkCommand(L *lua.LState) (interface{}, *options.RunCmdOptions, *Session) {
+	cmd := bsonutil.CastOrderedBSON(L, 2)
+	if _, ok := cmd.([]interface{}); ok {
+		L.ArgError(2, "command document expected")
+		return nil, nil, nil
+	}
+	cmd = emptyDocuments(cmd)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := runCmdOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return nil, nil, nil
+	}
+	return cmd, opts, sess
+}
+
+func databaseRunCommandMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+	cmd, opts, sess := checkCommand(L)
+
+	return runCommand(L, db.Client, db.Database, sess, cmd, opts)
+}
+
+// databaseRunCommandCursorMethod runs command returning a cursor, e.g. find,
+// aggregate or listCollections, the cursor is opened on first iteration
+func databaseRunCommandCursorMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+	cmd, opts, sess := checkCommand(L)
+
+	pushCursor(L, db.Client, sess, &commandQuery{
+		database: db.Database,
+		command:  cmd,
+		options:  opts,
+	})
+	return 1
+}
+
+func clientAdminCommandMethod(L *lua.LState) int {
+	client := checkClient(L)
+	cmd, opts, sess := checkCommand(L)
+
+	return runCommand(L, client, client.Client.Database("admin"), sess, cmd, opts)
+}
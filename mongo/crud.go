@@ -0,0 +1,373 @@
+package gluamongo_mongo
+
+import (
+	"fmt"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func insertOneOptions(opts interface{}) (*options.InsertOneOptions, error) {
+	io := options.InsertOne()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "bypassDocumentValidation":
+			var b bool
+			if b, err = toBool(v); err == nil {
+				io.SetBypassDocumentValidation(b)
+			}
+		case "comment":
+			io.SetComment(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return io, nil
+}
+
+func insertManyOptions(opts interface{}) (*options.InsertManyOptions, error) {
+	io := options.InsertMany()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var b bool
+		switch key {
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				io.SetBypassDocumentValidation(b)
+			}
+		case "ordered":
+			if b, err = toBool(v); err == nil {
+				io.SetOrdered(b)
+			}
+		case "comment":
+			io.SetComment(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return io, nil
+}
+
+func updateOptions(opts interface{}) (*options.UpdateOptions, error) {
+	uo := options.Update()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var b bool
+		switch key {
+		case "arrayFilters":
+			var af options.ArrayFilters
+			if af, err = toArrayFilters(v); err == nil {
+				uo.SetArrayFilters(af)
+			}
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				uo.SetBypassDocumentValidation(b)
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				uo.SetCollation(c)
+			}
+		case "comment":
+			uo.SetComment(v)
+		case "hint":
+			uo.SetHint(v)
+		case "let":
+			uo.SetLet(v)
+		case "upsert":
+			if b, err = toBool(v); err == nil {
+				uo.SetUpsert(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return uo, nil
+}
+
+func replaceOptions(opts interface{}) (*options.ReplaceOptions, error) {
+	ro := options.Replace()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var b bool
+		switch key {
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				ro.SetBypassDocumentValidation(b)
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				ro.SetCollation(c)
+			}
+		case "comment":
+			ro.SetComment(v)
+		case "hint":
+			ro.SetHint(v)
+		case "let":
+			ro.SetLet(v)
+		case "upsert":
+			if b, err = toBool(v); err == nil {
+				ro.SetUpsert(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return ro, nil
+}
+
+func deleteOptions(opts interface{}) (*options.DeleteOptions, error) {
+	do := options.Delete()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				do.SetCollation(c)
+			}
+		case "comment":
+			do.SetComment(v)
+		case "hint":
+			do.SetHint(v)
+		case "let":
+			do.SetLet(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return do, nil
+}
+
+func newInsertOneResult(res *mongo.InsertOneResult) map[string]interface{} {
+	return map[string]interface{}{
+		"insertedId": res.InsertedID,
+	}
+}
+
+// newInsertManyResult reports the ids keyed by 1-based document index as
+// bulkWrite does, the driver only returns the ids of the inserted documents
+func newInsertManyResult(res *mongo.InsertManyResult, n int, err error, ordered bool) map[string]interface{} {
+	failed := failedWrites(err, ordered)
+	ids := make(map[int64]interface{}, len(res.InsertedIDs))
+	for i, j := 0, 0; i < n && j < len(res.InsertedIDs); i++ {
+		if !failed(int64(i)) {
+			ids[int64(i+1)] = res.InsertedIDs[j]
+			j++
+		}
+	}
+	return map[string]interface{}{
+		"insertedCount": len(ids),
+		"insertedIds":   ids,
+	}
+}
+
+func newUpdateCountsResult(res *mongo.UpdateResult) map[string]interface{} {
+	return map[string]interface{}{
+		"matchedCount":  res.MatchedCount,
+		"modifiedCount": res.ModifiedCount,
+		"upsertedCount": res.UpsertedCount,
+		"upsertedId":    res.UpsertedID,
+	}
+}
+
+func newDeleteResult(res *mongo.DeleteResult) map[string]interface{} {
+	return map[string]interface{}{
+		"deletedCount": res.DeletedCount,
+	}
+}
+
+func collectionInsertOneMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	doc := bsonutil.CastBSON(L, 2)
+	if _, ok := doc.([]interface{}); ok {
+		L.ArgError(2, "document expected")
+		return 0
+	}
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := insertOneOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	res, err := coll.Collection.InsertOne(ctx, doc, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newInsertOneResult(res)))
+	return 1
+}
+
+// collectionInsertManyMethod inserts the documents, the ids generated for all
+// the documents are returned along with the error when some of them failed
+func collectionInsertManyMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	docs, ok := bsonutil.CastBSON(L, 2).([]interface{})
+	if !ok {
+		L.ArgError(2, "documents array expected")
+		return 0
+	}
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := insertManyOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	ordered := opts.Ordered == nil || *opts.Ordered
+	res, err := coll.Collection.InsertMany(ctx, docs, opts)
+	if err != nil {
+		if _, ok := err.(mongo.BulkWriteException); ok && res != nil {
+			L.Push(bsonutil.ToLuaValue(L, newInsertManyResult(res, len(docs), err, ordered)))
+		} else {
+			L.Push(lua.LNil)
+		}
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newInsertManyResult(res, len(docs), nil, ordered)))
+	return 1
+}
+
+// updateDocuments runs updateOne or updateMany with filter at 2, update at 3 and
+// options at 4
+func updateDocuments(L *lua.LState, many bool) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	document := bsonutil.CastBSON(L, 3)
+	rawOpts, sess := checkOptions(L, 4)
+	opts, err := updateOptions(rawOpts)
+	if err != nil {
+		L.ArgError(4, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	var res *mongo.UpdateResult
+	if many {
+		res, err = coll.Collection.UpdateMany(ctx, query, document, opts)
+	} else {
+		res, err = coll.Collection.UpdateOne(ctx, query, document, opts)
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newUpdateCountsResult(res)))
+	return 1
+}
+
+func collectionUpdateOneMethod(L *lua.LState) int {
+	return updateDocuments(L, false)
+}
+
+func collectionUpdateManyMethod(L *lua.LState) int {
+	return updateDocuments(L, true)
+}
+
+func collectionReplaceOneMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	replacement := bsonutil.CastBSON(L, 3)
+	rawOpts, sess := checkOptions(L, 4)
+	opts, err := replaceOptions(rawOpts)
+	if err != nil {
+		L.ArgError(4, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	res, err := coll.Collection.ReplaceOne(ctx, query, replacement, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newUpdateCountsResult(res)))
+	return 1
+}
+
+// deleteDocuments runs deleteOne or deleteMany with filter at 2 and options at 3
+func deleteDocuments(L *lua.LState, many bool) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := deleteOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	var res *mongo.DeleteResult
+	if many {
+		res, err = coll.Collection.DeleteMany(ctx, query, opts)
+	} else {
+		res, err = coll.Collection.DeleteOne(ctx, query, opts)
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, newDeleteResult(res)))
+	return 1
+}
+
+func collectionDeleteOneMethod(L *lua.LState) int {
+	return deleteDocuments(L, false)
+}
+
+func collectionDeleteManyMethod(L *lua.LState) int {
+	return deleteDocuments(L, true)
+}
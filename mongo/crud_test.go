@@ -0,0 +1,78 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestCRUD(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:deleteMany({}); -- remove all
+		local id = mongo.ObjectID();
+		local res, err = mcoll:insertOne({_id = id, a = 1});
+		local res2, err2 = mcoll:insertMany({{a = 2}, {a = 3}}, {ordered = false});
+		local res3, err3 = mcoll:updateOne({a = 1}, {["$set"] = {b = 1}});
+		local res4, err4 = mcoll:updateMany({a = {["$gte"] = 2}}, {["$set"] = {b = 2}});
+		local res5, err5 = mcoll:replaceOne({a = 4}, {a = 4}, {upsert = true});
+		local res6, err6 = mcoll:deleteOne({a = 4});
+		local res7, err7 = mcoll:deleteMany({});
+		mcoll:insertOne({a = 5}, {comment = 'crud'});
+		local res8, err8 = mcoll:updateOne({["$expr"] = {["$eq"] = {"$a", "$$x"}}}, {["$set"] = {b = 3}}, {let = {x = 5}, comment = 'crud'});
+		local res9, err9 = mcoll:insertMany({{_id = 1}, {_id = 1}, {_id = 2}}, {ordered = false});
+		mcoll:deleteMany({});
+		mongoClient:disconnect();
+		return res.insertedId == id, err, #res2.insertedIds, err2,
+		  res3.matchedCount, res3.modifiedCount, err3,
+		  res4.matchedCount, res4.modifiedCount, err4,
+		  res5.upsertedCount, res5.upsertedId ~= nil, err5,
+		  res6.deletedCount, err6, res7.deletedCount, err7, res8.modifiedCount, err8,
+		  res9.insertedCount, res9.insertedIds[1], res9.insertedIds[2], res9.insertedIds[3], err9:isDuplicateKey()
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(24, L.GetTop())
+	assert.Equal(lua.LTrue, L.Get(1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(2), L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNumber(1), L.Get(5))
+	assert.Equal(lua.LNumber(1), L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	assert.Equal(lua.LNumber(2), L.Get(8))
+	assert.Equal(lua.LNumber(2), L.Get(9))
+	assert.Equal(lua.LNil, L.Get(10))
+	assert.Equal(lua.LNumber(1), L.Get(11))
+	assert.Equal(lua.LTrue, L.Get(12))
+	assert.Equal(lua.LNil, L.Get(13))
+	assert.Equal(lua.LNumber(1), L.Get(14))
+	assert.Equal(lua.LNil, L.Get(15))
+	assert.Equal(lua.LNumber(3), L.Get(16))
+	assert.Equal(lua.LNil, L.Get(17))
+	assert.Equal(lua.LNumber(1), L.Get(18))
+	assert.Equal(lua.LNil, L.Get(19))
+	// the failed insert has no id
+	assert.Equal(lua.LNumber(2), L.Get(20))
+	assert.Equal(lua.LNumber(1), L.Get(21))
+	assert.Equal(lua.LNil, L.Get(22))
+	assert.Equal(lua.LNumber(2), L.Get(23))
+	assert.Equal(lua.LTrue, L.Get(24))
+}
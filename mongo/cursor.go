@@ -0,0 +1,395 @@
+package gluamongo_mongo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	CURSOR_TYPENAME = "mongo{cursor}"
+)
+
+// cursorQuery opens the underlying mongo cursor lazily
+type cursorQuery interface {
+	open(ctx context.Context) (*mongo.Cursor, error)
+	setBatchSize(n int32)
+}
+
+type findQuery struct {
+	collection *mongo.Collection
+	filter     interface{}
+	options    *options.FindOptions
+}
+
+func (q *findQuery) open(ctx context.Context) (*mongo.Cursor, error) {
+	return q.collection.Find(ctx, q.filter, q.options)
+}
+
+func (q *findQuery) setBatchSize(n int32) {
+	q.options.SetBatchSize(n)
+}
+
+// aggregator is implemented by both mongo.Collection and mongo.Database
+type aggregator interface {
+	Aggregate(ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (*mongo.Cursor, error)
+}
+
+type aggregateQuery struct {
+	target   aggregator
+	pipeline interface{}
+	options  *options.AggregateOptions
+}
+
+func (q *aggregateQuery) open(ctx context.Context) (*mongo.Cursor, error) {
+	return q.target.Aggregate(ctx, q.pipeline, q.options)
+}
+
+func (q *aggregateQuery) setBatchSize(n int32) {
+	q.options.SetBatchSize(n)
+}
+
+// Cursor mongo
+type Cursor struct {
+	Client  *Client
+	Cursor  *mongo.Cursor
+	Session *Session
+
+	query cursorQuery
+	// pending is true when cursor.Current holds a document not yet returned
+	pending bool
+	closed  bool
+}
+
+var cursorMethods = map[string]lua.LGFunction{
+	"batchSize": cursorBatchSizeMethod,
+	"close":     cursorCloseMethod,
+	"forEach":   cursorForEachMethod,
+	"hasNext":   cursorHasNextMethod,
+	"next":      cursorNextMethod,
+	"toArray":   cursorToArrayMethod,
+
+	// query modifiers, only before the cursor is opened
+	"collation":  cursorCollationMethod,
+	"comment":    cursorCommentMethod,
+	"hint":       cursorHintMethod,
+	"limit":      cursorLimitMethod,
+	"maxTimeMS":  cursorMaxTimeMSMethod,
+	"projection": cursorProjectionMethod,
+	"skip":       cursorSkipMethod,
+	"sort":       cursorSortMethod,
+}
+
+func pushCursor(L *lua.LState, client *Client, sess *Session, query cursorQuery) *Cursor {
+	c := &Cursor{
+		Client:  client,
+		Session: sess,
+		query:   query,
+	}
+	ud := L.NewUserData()
+	ud.Value = c
+	L.SetMetatable(ud, L.GetTypeMetatable(CURSOR_TYPENAME))
+	L.Push(ud)
+	return c
+}
+
+func checkCursor(L *lua.LState) *Cursor {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*Cursor); ok {
+		return v
+	}
+	L.ArgError(1, "mongo cursor expected")
+	return nil
+}
+
+// checkPendingCursor checks the cursor has not been opened yet
+func checkPendingCursor(L *lua.LState) *Cursor {
+	c := checkCursor(L)
+	if c.Cursor != nil || c.closed {
+		L.ArgError(1, "cursor already opened")
+		return nil
+	}
+	return c
+}
+
+func checkFindQuery(L *lua.LState) *findQuery {
+	c := checkPendingCursor(L)
+	if q, ok := c.query.(*findQuery); ok {
+		return q
+	}
+	L.ArgError(1, "find cursor expected")
+	return nil
+}
+
+// fetch moves the cursor to the next document, opening it on first use
+func (c *Cursor) fetch() (bool, error) {
+	if c.pending {
+		return true, nil
+	}
+	if c.closed {
+		return false, nil
+	}
+
+	ctx, cancel := c.Client.SessionContext(c.Session)
+	defer cancel()
+
+	if c.Cursor == nil {
+		cur, err := c.query.open(ctx)
+		if err != nil {
+			c.closed = true
+			return false, err
+		}
+		c.Cursor = cur
+	}
+	if c.Cursor.Next(ctx) {
+		c.pending = true
+		return true, nil
+	}
+	// exhausted or failed, the server side cursor is no longer needed
+	err := c.Cursor.Err()
+	_ = c.Cursor.Close(ctx)
+	c.closed = true
+	return false, err
+}
+
+// decode returns the pending document and consumes it
+func (c *Cursor) decode(L *lua.LState) (lua.LValue, error) {
+	c.pending = false
+	return c.Client.decode(L, c.Cursor.Current)
+}
+
+func (c *Cursor) next(L *lua.LState) (lua.LValue, error) {
+	ok, err := c.fetch()
+	if !ok {
+		return lua.LNil, err
+	}
+	return c.decode(L)
+}
+
+func cursorBatchSizeMethod(L *lua.LState) int {
+	c := checkPendingCursor(L)
+	n := L.CheckInt(2)
+
+	c.query.setBatchSize(int32(n))
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+// close releases the server side cursor, a cursor not opened yet is only
+// marked as closed
+func (c *Cursor) close() error {
+	c.pending = false
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.Cursor == nil {
+		return nil
+	}
+
+	ctx, cancel := c.Client.SessionContext(c.Session)
+	defer cancel()
+	return c.Cursor.Close(ctx)
+}
+
+func cursorCloseMethod(L *lua.LState) int {
+	c := checkCursor(L)
+
+	err := c.close()
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// cursorForEachMethod calls fn with each document, the cursor is closed when
+// fn returns false or raises an error
+func cursorForEachMethod(L *lua.LState) int {
+	c := checkCursor(L)
+	fn := L.CheckFunction(2)
+
+	for {
+		doc, err := c.next(L)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(LError(L, err))
+			return 2
+		}
+		if doc == lua.LNil {
+			break
+		}
+		L.Push(fn)
+		L.Push(doc)
+		if err := L.PCall(1, 1, nil); err != nil {
+			_ = c.close()
+			if apiErr, ok := err.(*lua.ApiError); ok {
+				L.Error(apiErr.Object, 0)
+			} else {
+				L.RaiseError(err.Error())
+			}
+			return 0
+		}
+		ret := L.Get(-1)
+		L.Pop(1)
+		if ret == lua.LFalse {
+			// returning false stops the iteration
+			_ = c.close()
+			break
+		}
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func cursorHasNextMethod(L *lua.LState) int {
+	c := checkCursor(L)
+
+	ok, err := c.fetch()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(ok))
+	return 1
+}
+
+func cursorNextMethod(L *lua.LState) int {
+	c := checkCursor(L)
+
+	doc, err := c.next(L)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(doc)
+	return 1
+}
+
+func cursorToArrayMethod(L *lua.LState) int {
+	c := checkCursor(L)
+
+	tb := L.NewTable()
+	for {
+		doc, err := c.next(L)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(LError(L, err))
+			return 2
+		}
+		if doc == lua.LNil {
+			break
+		}
+		tb.Append(doc)
+	}
+
+	L.Push(tb)
+	return 1
+}
+
+// cursorCallMethod makes cursor usable as a generic-for iterator:
+// for doc in cursor do ... end
+func cursorCallMethod(L *lua.LState) int {
+	c := checkCursor(L)
+
+	doc, err := c.next(L)
+	if err != nil {
+		L.Error(LError(L, err), 1)
+		return 0
+	}
+
+	L.Push(doc)
+	return 1
+}
+
+func cursorCollationMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+	collation, err := toCollation(bsonutil.CastBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	q.options.SetCollation(collation)
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorCommentMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+	comment := L.CheckString(2)
+
+	q.options.SetComment(comment)
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorHintMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+
+	// index name or index specification
+	if L.Get(2).Type() == lua.LTString && !strings.HasPrefix(L.ToString(2), "{") {
+		q.options.SetHint(L.ToString(2))
+	} else {
+		q.options.SetHint(bsonutil.CastBSON(L, 2))
+	}
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorLimitMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+	n := L.CheckInt64(2)
+
+	q.options.SetLimit(n)
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorMaxTimeMSMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+	ms := L.CheckInt64(2)
+
+	q.options.SetMaxTime(time.Duration(ms) * time.Millisecond)
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorProjectionMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+
+	q.options.SetProjection(bsonutil.CastBSON(L, 2))
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorSkipMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+	n := L.CheckInt64(2)
+
+	q.options.SetSkip(n)
+	L.Push(L.Get(1))
+	return 1
+}
+
+func cursorSortMethod(L *lua.LState) int {
+	q := checkFindQuery(L)
+
+	q.options.SetSort(bsonutil.CastBSON(L, 2))
+	L.Push(L.Get(1))
+	return 1
+}
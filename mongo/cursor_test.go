@@ -0,0 +1,171 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestCursor(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 1, b = 1}, {a = 1, b = 2}, {a = 1, b = 3}});
+
+		local cur = mcoll:cursor({a = 1}, {sort = {b = 1}}):batchSize(2);
+		local hasNext = cur:hasNext();
+		local first = cur:next();
+		local rest = cur:toArray();
+		local hasNext2 = cur:hasNext();
+		cur:close();
+
+		local n = 0;
+		for doc in mcoll:cursor({a = 1}) do
+		  n = n + doc.b;
+		end
+
+		local m = 0;
+		local cur2 = mcoll:cursor({a = 1}, {sort = {b = 1}}):batchSize(1);
+		cur2:forEach(function(doc)
+		  m = m + 1;
+		  return doc.b < 2;
+		end);
+
+		local cur3 = mcoll:cursor({a = 1}):batchSize(1);
+		local ok, err3 = pcall(cur3.forEach, cur3, function(doc)
+		  error('stop');
+		end);
+
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return hasNext, first.b, rest, hasNext2, n, m, cur2:hasNext(), ok, err3, cur3:hasNext()
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(10, L.GetTop())
+	assert.Equal(lua.LTrue, L.Get(1))
+	assert.Equal(lua.LNumber(1), L.Get(2))
+	v := bsonutil.GetValue(L, 3)
+	require.Len(v, 2)
+	assert.Equal(3, v.([]interface{})[1].(map[string]interface{})["b"])
+	assert.Equal(lua.LFalse, L.Get(4))
+	assert.Equal(lua.LNumber(6), L.Get(5))
+	assert.Equal(lua.LNumber(2), L.Get(6))
+	// forEach closes the cursor when stopped early or on errors
+	assert.Equal(lua.LFalse, L.Get(7))
+	assert.Equal(lua.LFalse, L.Get(8))
+	assert.Contains(L.ToString(9), "stop")
+	assert.Equal(lua.LFalse, L.Get(10))
+}
+
+func TestFindChaining(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 1, b = 1}, {a = 1, b = 2}, {a = 1, b = 3}, {a = 1, b = 4}});
+
+		local res, err = mcoll:find({a = 1}):sort({b = -1}):skip(1):limit(2):projection({_id = 0}):comment('chaining'):toArray();
+		local cur = mcoll:find({a = 1});
+		cur:next();
+		local ok, err2 = pcall(function() cur:sort({b = 1}) end);
+
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return res, err, ok, err2
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(4, L.GetTop())
+	assert.Equal([]interface{}{
+		map[string]interface{}{"a": 1, "b": 3},
+		map[string]interface{}{"a": 1, "b": 2},
+	}, bsonutil.GetValue(L, 1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LFalse, L.Get(3))
+	assert.Contains(L.ToString(4), "cursor already opened")
+}
+
+func TestAggregateCursor(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 1, b = 1}, {a = 1, b = 2}, {a = 2, b = 3}});
+		local pipeline = {{["$group"] = {_id = "$a", total = {["$sum"] = "$b"}}}, {["$sort"] = {_id = 1}}};
+		local cur, err = mcoll:aggregate(pipeline, {cursor = {batchSize = 1}, allowDiskUse = true, maxTimeMS = 1000});
+		local totals = {};
+		for doc in cur do
+		  table.insert(totals, doc.total);
+		end
+		local results, err2 = mcoll:aggregate(pipeline, {comment = 'test'});
+		local admin = mongoClient:getDatabase('admin');
+		local ops, err3 = admin:aggregate({{["$currentOp"] = {}}, {["$limit"] = 1}});
+		local bad, err4 = mcoll:aggregate({{["$bogus"] = {}}}, {cursor = true});
+		-- the pipeline runs even if the cursor is not iterated
+		local outCur, err5 = mcoll:aggregate({{["$match"] = {["$expr"] = {["$eq"] = {"$a", "$$x"}}}}, {["$out"] = "test_out"}}, {cursor = true, let = {x = 1}});
+		local out = mongoClient:getCollection('test', 'test_out');
+		local outDocs = out:find({}):toArray();
+		out:drop();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return totals, err, #results, err2, #ops, err3, bad, err4 ~= nil, err5, #outDocs
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(10, L.GetTop())
+	assert.Equal([]interface{}{3, 3}, bsonutil.GetValue(L, 1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(2), L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNumber(1), L.Get(5))
+	assert.Equal(lua.LNil, L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	assert.Equal(lua.LTrue, L.Get(8))
+	assert.Equal(lua.LNil, L.Get(9))
+	assert.Equal(lua.LNumber(2), L.Get(10))
+}
@@ -1,10 +1,14 @@
 package gluamongo_mongo
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/tengattack/gluamongo/bsonutil"
 	lua "github.com/yuin/gopher-lua"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
@@ -18,10 +22,18 @@ type Database struct {
 }
 
 var databaseMethods = map[string]lua.LGFunction{
-	// "drop":                nil,
+	"aggregate":          databaseAggregateMethod,
+	"createCollection":   databaseCreateCollectionMethod,
+	"createView":         databaseCreateViewMethod,
+	"drop":               databaseDropMethod,
 	"getCollection":      databaseGetCollectionMethod,
+	"getCollectionInfos": databaseGetCollectionInfosMethod,
 	"getCollectionNames": databaseGetCollectionNamesMethod,
 	"getName":            databaseGetNameMethod,
+	"gridfsBucket":       databaseGridFSBucketMethod,
+	"runCommand":         databaseRunCommandMethod,
+	"runCommandCursor":   databaseRunCommandCursorMethod,
+	"watch":              databaseWatchMethod,
 }
 
 func pushDatabase(L *lua.LState, client *Client, database *mongo.Database) {
@@ -43,6 +55,137 @@ func checkDatabase(L *lua.LState) *Database {
 	return nil
 }
 
+func databaseAggregateMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+
+	return aggregate(L, db.Client, db.Database)
+}
+
+// createCollectionKeys are the create command options in command order
+var createCollectionKeys = []string{
+	"capped",
+	"size",
+	"max",
+	"timeseries",
+	"expireAfterSeconds",
+	"clusteredIndex",
+	"validator",
+	"validationLevel",
+	"validationAction",
+	"collation",
+}
+
+// createCommand builds create command with the options, the command name
+// comes first
+func createCommand(name string, opts interface{}) (bson.D, error) {
+	cmd := bson.D{{Key: "create", Value: name}}
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range createCollectionKeys {
+		v, ok := m[key]
+		if !ok {
+			continue
+		}
+		switch key {
+		case "capped":
+			_, err = toBool(v)
+		case "size", "max", "expireAfterSeconds":
+			_, err = toNumber(v)
+		case "validationLevel", "validationAction":
+			_, err = toString(v)
+		case "collation":
+			_, err = toCollation(v)
+		case "timeseries", "clusteredIndex", "validator":
+			_, err = optionsMap(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+		cmd = append(cmd, bson.E{Key: key, Value: v})
+	}
+	return cmd, nil
+}
+
+// createCollection runs create command and pushes the created collection
+func createCollection(L *lua.LState, db *Database, sess *Session, name string, cmd bson.D) int {
+	ctx, cancel := db.Client.SessionContext(sess)
+	defer cancel()
+
+	err := db.Database.RunCommand(ctx, cmd).Err()
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	pushCollection(L, db.Client, db.Database.Collection(name))
+	return 1
+}
+
+func databaseCreateCollectionMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+	name := L.CheckString(2)
+
+	rawOpts, sess := checkOptions(L, 3)
+	cmd, err := createCommand(name, rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	return createCollection(L, db, sess, name, cmd)
+}
+
+// databaseCreateViewMethod creates view name on source collection with the
+// aggregation pipeline
+func databaseCreateViewMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+	name := L.CheckString(2)
+	source := L.CheckString(3)
+	pipeline := checkPipeline(L, 4)
+
+	rawOpts, sess := checkOptions(L, 5)
+	m, err := optionsMap(rawOpts)
+	if err != nil {
+		L.ArgError(5, err.Error())
+		return 0
+	}
+	cmd := bson.D{
+		{Key: "create", Value: name},
+		{Key: "viewOn", Value: source},
+		{Key: "pipeline", Value: pipeline},
+	}
+	if v, ok := m["collation"]; ok {
+		if _, err := toCollation(v); err != nil {
+			L.ArgError(5, fmt.Sprintf("invalid collation option: %v", err))
+			return 0
+		}
+		cmd = append(cmd, bson.E{Key: "collation", Value: v})
+	}
+
+	return createCollection(L, db, sess, name, cmd)
+}
+
+func databaseDropMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+	_, sess := checkOptions(L, 2)
+
+	ctx, cancel := db.Client.SessionContext(sess)
+	defer cancel()
+
+	err := db.Database.Drop(ctx)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
 func databaseGetCollectionMethod(L *lua.LState) int {
 	db := checkDatabase(L)
 
@@ -70,7 +213,7 @@ func databaseGetCollectionNamesMethod(L *lua.LState) int {
 	names, err := db.Database.ListCollectionNames(ctx, options)
 	if err != nil {
 		L.Push(lua.LBool(false))
-		L.Push(lua.LString(err.Error()))
+		L.Push(LError(L, err))
 		return 2
 	}
 
@@ -78,6 +221,51 @@ func databaseGetCollectionNamesMethod(L *lua.LState) int {
 	return 1
 }
 
+// databaseGetCollectionInfosMethod returns the full listCollections specs
+func databaseGetCollectionInfosMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+
+	var filter interface{} = bson.M{}
+	if L.Get(2) != lua.LNil {
+		filter = bsonutil.CastBSON(L, 2)
+	}
+	rawOpts, sess := checkOptions(L, 3)
+	m, err := optionsMap(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+	opts := options.ListCollections()
+	if v, ok := m["nameOnly"]; ok {
+		b, err := toBool(v)
+		if err != nil {
+			L.ArgError(3, "invalid nameOnly option")
+			return 0
+		}
+		opts.SetNameOnly(b)
+	}
+
+	ctx, cancel := db.Client.SessionContext(sess)
+	defer cancel()
+
+	cur, err := db.Database.ListCollections(ctx, filter, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	results, err := db.Client.decodeAll(ctx, L, cur)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(results)
+	return 1
+}
+
 func databaseGetNameMethod(L *lua.LState) int {
 	db := checkDatabase(L)
 
@@ -85,3 +273,11 @@ func databaseGetNameMethod(L *lua.LState) int {
 	L.Push(lua.LString(name))
 	return 1
 }
+
+func databaseWatchMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+
+	return watch(L, db.Client, func(ctx context.Context, pipeline interface{}, opts *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return db.Database.Watch(ctx, pipeline, opts)
+	})
+}
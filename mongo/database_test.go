@@ -39,3 +39,99 @@ func TestGetDatabase(t *testing.T) {
 	assert.Equal(lua.LNil, L.Get(2))
 	assert.NotEmpty(bsonutil.GetValue(L, 3))
 }
+
+func TestRunCommand(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:insert({{a = 1}, {a = 2}, {a = 3}});
+		local db = mongoClient:getDatabase('test');
+		local res, err = db:runCommand({count = 'test', query = {a = {["$gte"] = 2}}}, {readPreference = 'primaryPreferred'});
+		local cur, err2 = db:runCommandCursor({find = 'test', filter = {}, projection = {}, sort = {a = -1}});
+		local docs = cur:batchSize(1):toArray();
+		local res3, err3 = mongoClient:adminCommand({ping = 1});
+		local cur4, err4 = db:runCommandCursor({aggregate = 'test', pipeline = {}, cursor = {}});
+		local docs4 = cur4:toArray();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return res.n, err, docs[1].a, #docs, err2, res3.ok, err3, #docs4, err4
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(9, L.GetTop())
+	assert.Equal(lua.LNumber(2), L.Get(1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(3), L.Get(3))
+	assert.Equal(lua.LNumber(3), L.Get(4))
+	assert.Equal(lua.LNil, L.Get(5))
+	assert.Equal(lua.LNumber(1), L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	// nested empty tables are documents, except for pipeline
+	assert.Equal(lua.LNumber(3), L.Get(8))
+	assert.Equal(lua.LNil, L.Get(9))
+}
+
+func TestCollectionLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local db = mongoClient:getDatabase('test_lifecycle');
+		db:drop();
+		local coll, err = db:createCollection('capped', {capped = true, size = 4096, max = 2, validator = {a = {["$type"] = "number"}}});
+		coll:insert({{a = 1}, {a = 2}, {a = 3}});
+		local count = coll:count({});
+		local _, err2 = coll:insert({a = 'x'});
+		local view, err3 = db:createView('view', 'capped', {{["$match"] = {a = {["$gt"] = 2}}}});
+		local viewCount = view:count({});
+		local infos, err4 = db:getCollectionInfos({name = 'capped'});
+		local ok, err5 = coll:rename('renamed');
+		local names = db:getCollectionNames({name = 'renamed'});
+		local renamedCount = coll:count({});
+		local dropped, err6 = db:getCollection('renamed'):drop();
+		local dbDropped, err7 = db:drop();
+		mongoClient:disconnect();
+		return err, count, err2 ~= nil, err3, viewCount, infos[1].options.capped, err4, err5, #names, dropped, err6, dbDropped, err7, coll:getName(), renamedCount
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(15, L.GetTop())
+	assert.Equal(lua.LNil, L.Get(1))
+	assert.Equal(lua.LNumber(2), L.Get(2))
+	assert.Equal(lua.LTrue, L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNumber(1), L.Get(5))
+	assert.Equal(lua.LTrue, L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	assert.Equal(lua.LNil, L.Get(8))
+	assert.Equal(lua.LNumber(1), L.Get(9))
+	assert.Equal(lua.LTrue, L.Get(10))
+	assert.Equal(lua.LNil, L.Get(11))
+	assert.Equal(lua.LTrue, L.Get(12))
+	assert.Equal(lua.LNil, L.Get(13))
+	// the collection object follows the rename
+	assert.Equal("renamed", L.ToString(14))
+	assert.Equal(lua.LNumber(2), L.Get(15))
+}
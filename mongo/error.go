@@ -0,0 +1,223 @@
+package gluamongo_mongo
+
+import (
+	"errors"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ERROR_TYPENAME = "mongo{error}"
+)
+
+// Error mongo
+type Error struct {
+	Err error
+
+	Code              int
+	CodeName          string
+	Message           string
+	Labels            []string
+	WriteErrors       []mongo.WriteError
+	WriteConcernError *mongo.WriteConcernError
+}
+
+var errorMethods = map[string]lua.LGFunction{
+	"hasErrorLabel":  errorHasErrorLabelMethod,
+	"isDuplicateKey": errorIsDuplicateKeyMethod,
+	"isNetworkError": errorIsNetworkErrorMethod,
+	"isTimeout":      errorIsTimeoutMethod,
+}
+
+// NewError wraps go error with the details reported by the server
+func NewError(err error) *Error {
+	e := &Error{Err: err, Message: err.Error()}
+
+	var cmdErr mongo.CommandError
+	var writeErr mongo.WriteException
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &cmdErr) {
+		e.Code = int(cmdErr.Code)
+		e.CodeName = cmdErr.Name
+		e.Message = cmdErr.Message
+		e.Labels = cmdErr.Labels
+	} else if errors.As(err, &writeErr) {
+		e.Labels = writeErr.Labels
+		e.WriteErrors = writeErr.WriteErrors
+		e.WriteConcernError = writeErr.WriteConcernError
+	} else if errors.As(err, &bulkErr) {
+		e.Labels = bulkErr.Labels
+		for _, we := range bulkErr.WriteErrors {
+			e.WriteErrors = append(e.WriteErrors, we.WriteError)
+		}
+		e.WriteConcernError = bulkErr.WriteConcernError
+	}
+
+	// write exceptions are reported by the first error
+	if len(e.WriteErrors) > 0 {
+		e.Code = e.WriteErrors[0].Code
+		e.Message = e.WriteErrors[0].Message
+	} else if e.WriteConcernError != nil {
+		e.Code = e.WriteConcernError.Code
+		e.CodeName = e.WriteConcernError.Name
+		e.Message = e.WriteConcernError.Message
+	}
+	return e
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// LError creates Error value for glua
+func LError(L *lua.LState, err error) *lua.LUserData {
+	ud := L.NewUserData()
+	ud.Value = NewError(err)
+	L.SetMetatable(ud, L.GetTypeMetatable(ERROR_TYPENAME))
+	return ud
+}
+
+func checkError(L *lua.LState, idx int) *Error {
+	ud := L.CheckUserData(idx)
+	if v, ok := ud.Value.(*Error); ok {
+		return v
+	}
+	L.ArgError(idx, "mongo error expected")
+	return nil
+}
+
+func errorIndexMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+	key := L.CheckString(2)
+
+	switch key {
+	case "code":
+		if e.Code != 0 {
+			L.Push(lua.LNumber(e.Code))
+		} else {
+			L.Push(lua.LNil)
+		}
+	case "codeName":
+		if e.CodeName != "" {
+			L.Push(lua.LString(e.CodeName))
+		} else {
+			L.Push(lua.LNil)
+		}
+	case "message":
+		L.Push(lua.LString(e.Message))
+	case "labels":
+		labels := L.NewTable()
+		for _, label := range e.Labels {
+			labels.Append(lua.LString(label))
+		}
+		L.Push(labels)
+	case "writeErrors":
+		if e.WriteErrors == nil {
+			L.Push(lua.LNil)
+			return 1
+		}
+		// index is 1-based like insertedIds
+		writeErrors := L.NewTable()
+		for _, we := range e.WriteErrors {
+			tb := L.NewTable()
+			tb.RawSetString("index", lua.LNumber(we.Index+1))
+			tb.RawSetString("code", lua.LNumber(we.Code))
+			tb.RawSetString("message", lua.LString(we.Message))
+			writeErrors.Append(tb)
+		}
+		L.Push(writeErrors)
+	case "writeConcernError":
+		wce := e.WriteConcernError
+		if wce == nil {
+			L.Push(lua.LNil)
+			return 1
+		}
+		tb := L.NewTable()
+		tb.RawSetString("code", lua.LNumber(wce.Code))
+		tb.RawSetString("codeName", lua.LString(wce.Name))
+		tb.RawSetString("message", lua.LString(wce.Message))
+		if wce.Details != nil {
+			var details bson.M
+			if err := bson.Unmarshal(wce.Details, &details); err == nil {
+				tb.RawSetString("details", bsonutil.ToLuaValue(L, details))
+			}
+		}
+		L.Push(tb)
+	default:
+		if fn, ok := errorMethods[key]; ok {
+			L.Push(L.NewFunction(fn))
+		} else {
+			L.Push(lua.LNil)
+		}
+	}
+	return 1
+}
+
+func errorToStringMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+
+	L.Push(lua.LString(e.Error()))
+	return 1
+}
+
+// errorConcatMethod keeps string concatenation working as with string errors
+func errorConcatMethod(L *lua.LState) int {
+	lhs, rhs := L.Get(1), L.Get(2)
+	if ud, ok := lhs.(*lua.LUserData); ok {
+		if e, ok := ud.Value.(*Error); ok {
+			lhs = lua.LString(e.Error())
+		}
+	}
+	if ud, ok := rhs.(*lua.LUserData); ok {
+		if e, ok := ud.Value.(*Error); ok {
+			rhs = lua.LString(e.Error())
+		}
+	}
+
+	L.Push(lua.LString(lua.LVAsString(lhs) + lua.LVAsString(rhs)))
+	return 1
+}
+
+func errorHasErrorLabelMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+	label := L.CheckString(2)
+
+	for _, l := range e.Labels {
+		if l == label {
+			L.Push(lua.LTrue)
+			return 1
+		}
+	}
+	L.Push(lua.LFalse)
+	return 1
+}
+
+func errorIsDuplicateKeyMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+
+	L.Push(lua.LBool(mongo.IsDuplicateKeyError(e.Err)))
+	return 1
+}
+
+func errorIsNetworkErrorMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+
+	L.Push(lua.LBool(mongo.IsNetworkError(e.Err)))
+	return 1
+}
+
+func errorIsTimeoutMethod(L *lua.LState) int {
+	e := checkError(L, 1)
+
+	L.Push(lua.LBool(mongo.IsTimeout(e.Err)))
+	return 1
+}
@@ -0,0 +1,73 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestConnectError(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := `
+		local mongo = require 'mongo';
+		local mongoClient = mongo.Client()
+		mongoClient:set_timeout(100);
+		local ok, err = mongoClient:connect('mongodb://127.0.0.1:1/admin');
+		return ok, tostring(err), err.message, 'error: ' .. err, err:isTimeout(), err:isDuplicateKey(), #err.labels, err.code
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(8, L.GetTop())
+	assert.Equal(lua.LNil, L.Get(1))
+	assert.Contains(L.ToString(2), "server selection error")
+	assert.Equal(L.ToString(2), L.ToString(3))
+	assert.Equal("error: "+L.ToString(2), L.ToString(4))
+	assert.Equal(lua.LTrue, L.Get(5))
+	assert.Equal(lua.LFalse, L.Get(6))
+	assert.Equal(lua.LNumber(0), L.Get(7))
+	assert.Equal(lua.LNil, L.Get(8))
+}
+
+func TestWriteErrorIndex(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:deleteMany({}); -- remove all
+		local docs = {{_id = 1}, {_id = 2}, {_id = 1}};
+		local res, err = mcoll:insertMany(docs);
+		mcoll:deleteMany({});
+		mongoClient:disconnect();
+		local we = err.writeErrors[1];
+		return #err.writeErrors, we.index, docs[we.index]._id, err:isDuplicateKey()
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(4, L.GetTop())
+	assert.Equal(lua.LNumber(1), L.Get(1))
+	assert.Equal(lua.LNumber(3), L.Get(2))
+	assert.Equal(lua.LNumber(1), L.Get(3))
+	assert.Equal(lua.LTrue, L.Get(4))
+}
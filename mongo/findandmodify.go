@@ -0,0 +1,232 @@
+package gluamongo_mongo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// toReturnDocument accepts "before" or "after"
+func toReturnDocument(v interface{}) (options.ReturnDocument, error) {
+	str, err := toString(v)
+	if err != nil {
+		return 0, err
+	}
+	switch str {
+	case "before":
+		return options.Before, nil
+	case "after":
+		return options.After, nil
+	}
+	return 0, fmt.Errorf("unknown value: %s", str)
+}
+
+func findOneAndUpdateOptions(opts interface{}) (*options.FindOneAndUpdateOptions, error) {
+	fo := options.FindOneAndUpdate()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var b bool
+		switch key {
+		case "arrayFilters":
+			var af options.ArrayFilters
+			if af, err = toArrayFilters(v); err == nil {
+				fo.SetArrayFilters(af)
+			}
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				fo.SetBypassDocumentValidation(b)
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				fo.SetCollation(c)
+			}
+		case "hint":
+			fo.SetHint(v)
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				fo.SetMaxTime(d)
+			}
+		case "projection":
+			fo.SetProjection(v)
+		case "returnDocument":
+			var rd options.ReturnDocument
+			if rd, err = toReturnDocument(v); err == nil {
+				fo.SetReturnDocument(rd)
+			}
+		case "sort":
+			fo.SetSort(v)
+		case "upsert":
+			if b, err = toBool(v); err == nil {
+				fo.SetUpsert(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return fo, nil
+}
+
+func findOneAndReplaceOptions(opts interface{}) (*options.FindOneAndReplaceOptions, error) {
+	fo := options.FindOneAndReplace()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var b bool
+		switch key {
+		case "bypassDocumentValidation":
+			if b, err = toBool(v); err == nil {
+				fo.SetBypassDocumentValidation(b)
+			}
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				fo.SetCollation(c)
+			}
+		case "hint":
+			fo.SetHint(v)
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				fo.SetMaxTime(d)
+			}
+		case "projection":
+			fo.SetProjection(v)
+		case "returnDocument":
+			var rd options.ReturnDocument
+			if rd, err = toReturnDocument(v); err == nil {
+				fo.SetReturnDocument(rd)
+			}
+		case "sort":
+			fo.SetSort(v)
+		case "upsert":
+			if b, err = toBool(v); err == nil {
+				fo.SetUpsert(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return fo, nil
+}
+
+func findOneAndDeleteOptions(opts interface{}) (*options.FindOneAndDeleteOptions, error) {
+	fo := options.FindOneAndDelete()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				fo.SetCollation(c)
+			}
+		case "hint":
+			fo.SetHint(v)
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				fo.SetMaxTime(d)
+			}
+		case "projection":
+			fo.SetProjection(v)
+		case "sort":
+			fo.SetSort(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return fo, nil
+}
+
+// pushSingleResult pushes the decoded document, or nil when nothing matched
+func pushSingleResult(L *lua.LState, client *Client, res *mongo.SingleResult) int {
+	raw, err := res.DecodeBytes()
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	result, err := client.decode(L, raw)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(result)
+	return 1
+}
+
+func collectionFindOneAndDeleteMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := findOneAndDeleteOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	return pushSingleResult(L, coll.Client, coll.Collection.FindOneAndDelete(ctx, query, opts))
+}
+
+func collectionFindOneAndReplaceMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	replacement := bsonutil.CastBSON(L, 3)
+	rawOpts, sess := checkOptions(L, 4)
+	opts, err := findOneAndReplaceOptions(rawOpts)
+	if err != nil {
+		L.ArgError(4, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	return pushSingleResult(L, coll.Client, coll.Collection.FindOneAndReplace(ctx, query, replacement, opts))
+}
+
+func collectionFindOneAndUpdateMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	query := bsonutil.CastBSON(L, 2)
+	update := bsonutil.CastBSON(L, 3)
+	rawOpts, sess := checkOptions(L, 4)
+	opts, err := findOneAndUpdateOptions(rawOpts)
+	if err != nil {
+		L.ArgError(4, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	return pushSingleResult(L, coll.Client, coll.Collection.FindOneAndUpdate(ctx, query, update, opts))
+}
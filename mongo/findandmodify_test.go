@@ -0,0 +1,52 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestFindOneAndModify(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		local doc, err = mcoll:findOneAndUpdate({name = 'counter'}, {["$inc"] = {seq = 1}}, {upsert = true, returnDocument = 'after'});
+		local before, err2 = mcoll:findOneAndUpdate({name = 'counter'}, {["$inc"] = {seq = 1}}, {projection = {_id = 0}});
+		local replaced, err3 = mcoll:findOneAndReplace({name = 'counter'}, {name = 'counter', seq = 10}, {returnDocument = 'after'});
+		local deleted, err4 = mcoll:findOneAndDelete({name = 'counter'});
+		local missing, err5 = mcoll:findOneAndDelete({name = 'counter'});
+		mongoClient:disconnect();
+		return doc.seq, err, before.seq, before._id, err2, replaced.seq, err3, deleted.seq, err4, missing, err5
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(11, L.GetTop())
+	assert.Equal(lua.LNumber(1), L.Get(1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNumber(1), L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNil, L.Get(5))
+	assert.Equal(lua.LNumber(10), L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	assert.Equal(lua.LNumber(10), L.Get(8))
+	assert.Equal(lua.LNil, L.Get(9))
+	assert.Equal(lua.LNil, L.Get(10))
+	assert.Equal(lua.LNil, L.Get(11))
+}
@@ -0,0 +1,601 @@
+package gluamongo_mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	GRIDFSBUCKET_TYPENAME         = "mongo{gridfsbucket}"
+	GRIDFSUPLOADSTREAM_TYPENAME   = "mongo{gridfsuploadstream}"
+	GRIDFSDOWNLOADSTREAM_TYPENAME = "mongo{gridfsdownloadstream}"
+)
+
+// GridFSBucket mongo
+type GridFSBucket struct {
+	Client *Client
+	Bucket *gridfs.Bucket
+}
+
+// GridFSUploadStream mongo
+type GridFSUploadStream struct {
+	Client       *Client
+	UploadStream *gridfs.UploadStream
+}
+
+// GridFSDownloadStream mongo
+type GridFSDownloadStream struct {
+	Client         *Client
+	DownloadStream *gridfs.DownloadStream
+}
+
+var gridfsBucketMethods = map[string]lua.LGFunction{
+	"delete":             gridfsBucketDeleteMethod,
+	"download":           gridfsBucketDownloadMethod,
+	"drop":               gridfsBucketDropMethod,
+	"find":               gridfsBucketFindMethod,
+	"openDownloadStream": gridfsBucketOpenDownloadStreamMethod,
+	"openUploadStream":   gridfsBucketOpenUploadStreamMethod,
+	"rename":             gridfsBucketRenameMethod,
+	"upload":             gridfsBucketUploadMethod,
+}
+
+var gridfsUploadStreamMethods = map[string]lua.LGFunction{
+	"abort": gridfsUploadStreamAbortMethod,
+	"close": gridfsUploadStreamCloseMethod,
+	"id":    gridfsUploadStreamIDMethod,
+	"write": gridfsUploadStreamWriteMethod,
+}
+
+var gridfsDownloadStreamMethods = map[string]lua.LGFunction{
+	"close": gridfsDownloadStreamCloseMethod,
+	"file":  gridfsDownloadStreamFileMethod,
+	"read":  gridfsDownloadStreamReadMethod,
+}
+
+type gridfsFindQuery struct {
+	bucket  *gridfs.Bucket
+	filter  interface{}
+	options *options.GridFSFindOptions
+	timeout time.Duration
+}
+
+func (q *gridfsFindQuery) open(_ context.Context) (*mongo.Cursor, error) {
+	// the bucket uses read deadline instead of context
+	q.bucket.SetReadDeadline(time.Now().Add(q.timeout))
+	return q.bucket.Find(q.filter, q.options)
+}
+
+func (q *gridfsFindQuery) setBatchSize(n int32) {
+	q.options.SetBatchSize(n)
+}
+
+// luaReader reads from lua object with read method, e.g. lua file
+type luaReader struct {
+	L   *lua.LState
+	obj lua.LValue
+	buf []byte
+}
+
+func (r *luaReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		err := r.L.CallByParam(lua.P{
+			Fn:      r.L.GetField(r.obj, "read"),
+			NRet:    1,
+			Protect: true,
+		}, r.obj, lua.LNumber(len(p)))
+		if err != nil {
+			return 0, err
+		}
+		ret := r.L.Get(-1)
+		r.L.Pop(1)
+		if ret == lua.LNil {
+			return 0, io.EOF
+		}
+		str, ok := ret.(lua.LString)
+		if !ok {
+			return 0, fmt.Errorf("read returns %s, string expected", ret.Type())
+		}
+		r.buf = []byte(str)
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func pushGridFSBucket(L *lua.LState, client *Client, bucket *gridfs.Bucket) {
+	ud := L.NewUserData()
+	ud.Value = &GridFSBucket{
+		Client: client,
+		Bucket: bucket,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(GRIDFSBUCKET_TYPENAME))
+	L.Push(ud)
+}
+
+func checkGridFSBucket(L *lua.LState) *GridFSBucket {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*GridFSBucket); ok {
+		return v
+	}
+	L.ArgError(1, "mongo gridfs bucket expected")
+	return nil
+}
+
+func checkGridFSUploadStream(L *lua.LState) *GridFSUploadStream {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*GridFSUploadStream); ok {
+		return v
+	}
+	L.ArgError(1, "mongo gridfs upload stream expected")
+	return nil
+}
+
+func checkGridFSDownloadStream(L *lua.LState) *GridFSDownloadStream {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*GridFSDownloadStream); ok {
+		return v
+	}
+	L.ArgError(1, "mongo gridfs download stream expected")
+	return nil
+}
+
+// checkFileID gets file id at idx, usually an ObjectID
+func checkFileID(L *lua.LState, idx int) interface{} {
+	id := bsonutil.GetValue(L, idx)
+	if id == nil {
+		L.ArgError(idx, "file id expected")
+		return nil
+	}
+	return id
+}
+
+func bucketOptions(opts interface{}) (*options.BucketOptions, error) {
+	bo := options.GridFSBucket()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "bucketName":
+			var str string
+			if str, err = toString(v); err == nil {
+				bo.SetName(str)
+			}
+		case "chunkSizeBytes":
+			var i int32
+			if i, err = toInt32(v); err == nil {
+				bo.SetChunkSizeBytes(i)
+			}
+		case "readConcern":
+			rc, err2 := toReadConcern(v)
+			if err = err2; err == nil {
+				bo.SetReadConcern(rc)
+			}
+		case "readPreference":
+			rp, err2 := toReadPref(v)
+			if err = err2; err == nil {
+				bo.SetReadPreference(rp)
+			}
+		case "writeConcern":
+			wc, err2 := toWriteConcern(v)
+			if err = err2; err == nil {
+				bo.SetWriteConcern(wc)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return bo, nil
+}
+
+func uploadOptions(opts interface{}) (*options.UploadOptions, error) {
+	uo := options.GridFSUpload()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "chunkSizeBytes":
+			var i int32
+			if i, err = toInt32(v); err == nil {
+				uo.SetChunkSizeBytes(i)
+			}
+		case "metadata":
+			uo.SetMetadata(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return uo, nil
+}
+
+func gridfsFindOptions(opts interface{}) (*options.GridFSFindOptions, error) {
+	fo := options.GridFSFind()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var i int32
+		switch key {
+		case "batchSize":
+			if i, err = toInt32(v); err == nil {
+				fo.SetBatchSize(i)
+			}
+		case "limit":
+			if i, err = toInt32(v); err == nil {
+				fo.SetLimit(i)
+			}
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				fo.SetMaxTime(d)
+			}
+		case "noCursorTimeout":
+			var b bool
+			if b, err = toBool(v); err == nil {
+				fo.SetNoCursorTimeout(b)
+			}
+		case "skip":
+			if i, err = toInt32(v); err == nil {
+				fo.SetSkip(i)
+			}
+		case "sort":
+			fo.SetSort(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return fo, nil
+}
+
+// databaseGridFSBucketMethod creates gridfs bucket, the bucket operations use
+// deadlines from the client timeout
+func databaseGridFSBucketMethod(L *lua.LState) int {
+	db := checkDatabase(L)
+
+	opts, err := bucketOptions(bsonutil.ToBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	bucket, err := gridfs.NewBucket(db.Database, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	pushGridFSBucket(L, db.Client, bucket)
+	return 1
+}
+
+func (b *GridFSBucket) deadline() time.Time {
+	return time.Now().Add(b.Client.Timeout)
+}
+
+func gridfsBucketDeleteMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	id := checkFileID(L, 2)
+
+	b.Bucket.SetWriteDeadline(b.deadline())
+	err := b.Bucket.Delete(id)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// gridfsBucketDownloadMethod downloads the whole file as string
+func gridfsBucketDownloadMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	id := checkFileID(L, 2)
+
+	var buf bytes.Buffer
+	b.Bucket.SetReadDeadline(b.deadline())
+	_, err := b.Bucket.DownloadToStream(id, &buf)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LString(buf.String()))
+	return 1
+}
+
+func gridfsBucketDropMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+
+	b.Bucket.SetWriteDeadline(b.deadline())
+	err := b.Bucket.Drop()
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// gridfsBucketFindMethod returns a lazy cursor of the files collection
+func gridfsBucketFindMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+
+	var filter interface{} = bson.M{}
+	if L.Get(2) != lua.LNil {
+		filter = bsonutil.CastBSON(L, 2)
+	}
+	opts, err := gridfsFindOptions(bsonutil.ToBSON(L, 3))
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	pushCursor(L, b.Client, nil, &gridfsFindQuery{
+		bucket:  b.Bucket,
+		filter:  filter,
+		options: opts,
+		timeout: b.Client.Timeout,
+	})
+	return 1
+}
+
+func gridfsBucketOpenDownloadStreamMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	id := checkFileID(L, 2)
+
+	b.Bucket.SetReadDeadline(b.deadline())
+	ds, err := b.Bucket.OpenDownloadStream(id)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	ud := L.NewUserData()
+	ud.Value = &GridFSDownloadStream{
+		Client:         b.Client,
+		DownloadStream: ds,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(GRIDFSDOWNLOADSTREAM_TYPENAME))
+	L.Push(ud)
+	return 1
+}
+
+func gridfsBucketOpenUploadStreamMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	name := L.CheckString(2)
+
+	opts, err := uploadOptions(bsonutil.ToBSON(L, 3))
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	b.Bucket.SetWriteDeadline(b.deadline())
+	us, err := b.Bucket.OpenUploadStream(name, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	ud := L.NewUserData()
+	ud.Value = &GridFSUploadStream{
+		Client:       b.Client,
+		UploadStream: us,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(GRIDFSUPLOADSTREAM_TYPENAME))
+	L.Push(ud)
+	return 1
+}
+
+func gridfsBucketRenameMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	id := checkFileID(L, 2)
+	name := L.CheckString(3)
+
+	b.Bucket.SetWriteDeadline(b.deadline())
+	err := b.Bucket.Rename(id, name)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// gridfsBucketUploadMethod uploads string or object with read method, e.g.
+// lua file, and returns the file id
+func gridfsBucketUploadMethod(L *lua.LState) int {
+	b := checkGridFSBucket(L)
+	name := L.CheckString(2)
+
+	var source io.Reader
+	switch lv := L.Get(3); lv.Type() {
+	case lua.LTString:
+		source = bytes.NewBufferString(lua.LVAsString(lv))
+	case lua.LTUserData, lua.LTTable:
+		if L.GetField(lv, "read").Type() != lua.LTFunction {
+			L.ArgError(3, "string or file expected")
+			return 0
+		}
+		source = &luaReader{L: L, obj: lv}
+	default:
+		L.ArgError(3, "string or file expected")
+		return 0
+	}
+	opts := options.GridFSUpload()
+	if metadata := bsonutil.ToBSON(L, 4); metadata != nil {
+		opts.SetMetadata(metadata)
+	}
+
+	b.Bucket.SetWriteDeadline(b.deadline())
+	id, err := b.Bucket.UploadFromStream(name, source, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.LObjectID(L, id))
+	return 1
+}
+
+func gridfsUploadStreamAbortMethod(L *lua.LState) int {
+	us := checkGridFSUploadStream(L)
+
+	us.UploadStream.SetWriteDeadline(time.Now().Add(us.Client.Timeout))
+	err := us.UploadStream.Abort()
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// gridfsUploadStreamCloseMethod flushes the remaining data and creates the
+// files collection document
+func gridfsUploadStreamCloseMethod(L *lua.LState) int {
+	us := checkGridFSUploadStream(L)
+
+	us.UploadStream.SetWriteDeadline(time.Now().Add(us.Client.Timeout))
+	err := us.UploadStream.Close()
+	if err != nil && !errors.Is(err, gridfs.ErrStreamClosed) {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func gridfsUploadStreamIDMethod(L *lua.LState) int {
+	us := checkGridFSUploadStream(L)
+
+	L.Push(bsonutil.ToLuaValue(L, us.UploadStream.FileID))
+	return 1
+}
+
+// gridfsUploadStreamWriteMethod writes strings, returns the stream like
+// file:write
+func gridfsUploadStreamWriteMethod(L *lua.LState) int {
+	us := checkGridFSUploadStream(L)
+
+	us.UploadStream.SetWriteDeadline(time.Now().Add(us.Client.Timeout))
+	for i := 2; i <= L.GetTop(); i++ {
+		_, err := us.UploadStream.Write([]byte(L.CheckString(i)))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(LError(L, err))
+			return 2
+		}
+	}
+
+	L.Push(L.Get(1))
+	return 1
+}
+
+func gridfsDownloadStreamCloseMethod(L *lua.LState) int {
+	ds := checkGridFSDownloadStream(L)
+
+	err := ds.DownloadStream.Close()
+	if err != nil && !errors.Is(err, gridfs.ErrStreamClosed) {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func gridfsDownloadStreamFileMethod(L *lua.LState) int {
+	ds := checkGridFSDownloadStream(L)
+
+	f := ds.DownloadStream.GetFile()
+	tb := L.NewTable()
+	tb.RawSetString("_id", bsonutil.ToLuaValue(L, f.ID))
+	tb.RawSetString("length", lua.LNumber(f.Length))
+	tb.RawSetString("chunkSize", lua.LNumber(f.ChunkSize))
+	tb.RawSetString("uploadDate", bsonutil.LDateTime(L, primitive.NewDateTimeFromTime(f.UploadDate)))
+	tb.RawSetString("filename", lua.LString(f.Name))
+	if f.Metadata != nil {
+		if metadata, err := ds.Client.decode(L, f.Metadata); err == nil {
+			tb.RawSetString("metadata", metadata)
+		}
+	}
+
+	L.Push(tb)
+	return 1
+}
+
+// gridfsDownloadStreamReadMethod reads n bytes, or the rest of the file
+// without n or with "*a", nil is returned at the end like file:read
+func gridfsDownloadStreamReadMethod(L *lua.LState) int {
+	ds := checkGridFSDownloadStream(L)
+
+	ds.DownloadStream.SetReadDeadline(time.Now().Add(ds.Client.Timeout))
+	if L.Get(2).Type() != lua.LTNumber {
+		data, err := ioutil.ReadAll(ds.DownloadStream)
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(LError(L, err))
+			return 2
+		}
+		L.Push(lua.LString(data))
+		return 1
+	}
+
+	size := L.CheckInt(2)
+	if size < 0 {
+		L.ArgError(2, "non-negative size expected")
+		return 0
+	}
+	buf := make([]byte, size)
+	n, err := ds.DownloadStream.Read(buf)
+	if err == io.EOF {
+		L.Push(lua.LNil)
+		return 1
+	}
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LString(buf[:n]))
+	return 1
+}
@@ -0,0 +1,83 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestGridFSBucket(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local db = mongoClient:getDatabase('test');
+		local bucket, err = db:gridfsBucket({bucketName = 'reports', chunkSizeBytes = 4});
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		bucket:drop();
+
+		local id, err = bucket:upload('a.txt', 'hello gridfs', {kind = 'report'});
+		local data, err2 = bucket:download(id);
+
+		local name = os.tmpname();
+		local f = io.open(name, 'w');
+		f:write('from lua file');
+		f:close();
+		f = io.open(name, 'r');
+		local id2, err3 = bucket:upload('b.txt', f);
+		f:close();
+		os.remove(name);
+		local data2 = bucket:download(id2);
+
+		local us = bucket:openUploadStream('c.txt', {metadata = {kind = 'stream'}});
+		us:write('chunked', ' ', 'write');
+		us:close();
+		local ds = bucket:openDownloadStream(us:id());
+		local part = ds:read(7);
+		local rest = ds:read('*a');
+		local eof = ds:read(1);
+		local readOk = pcall(ds.read, ds, -1);
+		local file = ds:file();
+		ds:close();
+
+		bucket:rename(id2, 'renamed.txt');
+		local files = bucket:find({filename = 'renamed.txt'}):toArray();
+		local ok, err4 = bucket:delete(id);
+		local remaining = bucket:find({}, {sort = {filename = 1}}):toArray();
+		bucket:drop();
+		mongoClient:disconnect();
+		return err, data, err2, err3, data2, part, rest, eof,
+		  file.filename, file.metadata.kind, #files, ok, err4, #remaining, readOk
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(15, L.GetTop())
+	assert.Equal(lua.LNil, L.Get(1))
+	assert.Equal("hello gridfs", L.ToString(2))
+	assert.Equal(lua.LNil, L.Get(3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal("from lua file", L.ToString(5))
+	assert.Equal("chunked", L.ToString(6))
+	assert.Equal(" write", L.ToString(7))
+	assert.Equal(lua.LNil, L.Get(8))
+	assert.Equal("c.txt", L.ToString(9))
+	assert.Equal("stream", L.ToString(10))
+	assert.Equal(lua.LNumber(1), L.Get(11))
+	assert.Equal(lua.LTrue, L.Get(12))
+	assert.Equal(lua.LNil, L.Get(13))
+	assert.Equal(lua.LNumber(2), L.Get(14))
+	assert.Equal(lua.LFalse, L.Get(15))
+}
@@ -0,0 +1,420 @@
+package gluamongo_mongo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexOptions parses index options, operation options (session, maxTimeMS
+// and commitQuorum) are skipped
+func indexOptions(opts interface{}) (*options.IndexOptions, error) {
+	io := options.Index()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		var i int32
+		var f float64
+		var b bool
+		var str string
+		switch key {
+		case "background":
+			if b, err = toBool(v); err == nil {
+				io.SetBackground(b)
+			}
+		case "expireAfterSeconds":
+			if i, err = toInt32(v); err == nil {
+				io.SetExpireAfterSeconds(i)
+			}
+		case "name":
+			if str, err = toString(v); err == nil {
+				io.SetName(str)
+			}
+		case "sparse":
+			if b, err = toBool(v); err == nil {
+				io.SetSparse(b)
+			}
+		case "storageEngine":
+			io.SetStorageEngine(v)
+		case "unique":
+			if b, err = toBool(v); err == nil {
+				io.SetUnique(b)
+			}
+		case "v":
+			if i, err = toInt32(v); err == nil {
+				io.SetVersion(i)
+			}
+		case "default_language":
+			if str, err = toString(v); err == nil {
+				io.SetDefaultLanguage(str)
+			}
+		case "language_override":
+			if str, err = toString(v); err == nil {
+				io.SetLanguageOverride(str)
+			}
+		case "textIndexVersion":
+			if i, err = toInt32(v); err == nil {
+				io.SetTextVersion(i)
+			}
+		case "weights":
+			io.SetWeights(v)
+		case "2dsphereIndexVersion":
+			if i, err = toInt32(v); err == nil {
+				io.SetSphereVersion(i)
+			}
+		case "bits":
+			if i, err = toInt32(v); err == nil {
+				io.SetBits(i)
+			}
+		case "max":
+			if f, err = toFloat(v); err == nil {
+				io.SetMax(f)
+			}
+		case "min":
+			if f, err = toFloat(v); err == nil {
+				io.SetMin(f)
+			}
+		case "bucketSize":
+			if i, err = toInt32(v); err == nil {
+				io.SetBucketSize(i)
+			}
+		case "partialFilterExpression":
+			io.SetPartialFilterExpression(v)
+		case "collation":
+			var c *options.Collation
+			if c, err = toCollation(v); err == nil {
+				io.SetCollation(c)
+			}
+		case "wildcardProjection":
+			io.SetWildcardProjection(v)
+		case "hidden":
+			if b, err = toBool(v); err == nil {
+				io.SetHidden(b)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return io, nil
+}
+
+func createIndexesOptions(opts interface{}) (*options.CreateIndexesOptions, error) {
+	co := options.CreateIndexes()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "commitQuorum":
+			if str, ok := v.(string); ok {
+				co.SetCommitQuorumString(str)
+			} else {
+				var i int32
+				if i, err = toInt32(v); err == nil {
+					co.SetCommitQuorumInt(i)
+				}
+			}
+		case "maxTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				co.SetMaxTime(d)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return co, nil
+}
+
+// checkIndexName gets index name or key specification at idx, a string
+// starting with { is a json key specification
+func checkIndexName(L *lua.LState, idx int) interface{} {
+	if L.Get(idx).Type() == lua.LTString && !strings.HasPrefix(L.ToString(idx), "{") {
+		return L.ToString(idx)
+	}
+	return bsonutil.CastBSON(L, idx)
+}
+
+// runCollectionCommand runs command on the database of the collection
+func runCollectionCommand(L *lua.LState, coll *Collection, sess *Session, cmd bson.D) int {
+	return runCommand(L, coll.Client, coll.Collection.Database(), sess, cmd, nil)
+}
+
+func collectionCreateIndexMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	keys := bsonutil.CastBSON(L, 2)
+	rawOpts, sess := checkOptions(L, 3)
+	opts, err := indexOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+	createOpts, err := createIndexesOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	name, err := coll.Collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: opts,
+	}, createOpts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LString(name))
+	return 1
+}
+
+// checkKeyPattern checks the key pattern at position i of the key patterns
+// array at idx, a compound key pattern must keep the key order so it has to be
+// a mongo.Doc or an extended json string, a table with more than one key is
+// rejected by the driver
+func checkKeyPattern(L *lua.LState, idx, i int, keys interface{}) interface{} {
+	switch v := keys.(type) {
+	case string:
+		doc, err := bsonutil.UnmarshalBSON(v)
+		if err == nil {
+			if d, ok := doc.(bson.D); ok {
+				return d
+			}
+		}
+	case map[string]interface{}:
+		return v
+	case bson.D:
+		return v
+	}
+	L.ArgError(idx, fmt.Sprintf("invalid key pattern #%d: document expected", i+1))
+	return nil
+}
+
+// collectionCreateIndexesMethod creates indexes with key patterns at 2, the
+// options at 3 apply to all the indexes, e.g.
+//
+//	coll:createIndexes({{a = 1}, '{"b": 1, "c": -1}', mongo.Doc({{"d", 1}, {"e", -1}})})
+func collectionCreateIndexesMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	keyPatterns, ok := bsonutil.CastBSON(L, 2).([]interface{})
+	if !ok {
+		L.ArgError(2, "key patterns array expected")
+		return 0
+	}
+	rawOpts, sess := checkOptions(L, 3)
+	createOpts, err := createIndexesOptions(rawOpts)
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	models := make([]mongo.IndexModel, 0, len(keyPatterns))
+	for i, keys := range keyPatterns {
+		keys = checkKeyPattern(L, 2, i, keys)
+		// index options are not shared between models
+		opts, err := indexOptions(rawOpts)
+		if err != nil {
+			L.ArgError(3, err.Error())
+			return 0
+		}
+		models = append(models, mongo.IndexModel{
+			Keys:    keys,
+			Options: opts,
+		})
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	names, err := coll.Collection.Indexes().CreateMany(ctx, models, createOpts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(bsonutil.ToLuaValue(L, names))
+	return 1
+}
+
+func collectionDropIndexMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	index := checkIndexName(L, 2)
+	_, sess := checkOptions(L, 3)
+
+	name, ok := index.(string)
+	if !ok {
+		// key specification is not supported by IndexView
+		return runCollectionCommand(L, coll, sess, bson.D{
+			{Key: "dropIndexes", Value: coll.Collection.Name()},
+			{Key: "index", Value: index},
+		})
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	res, err := coll.Collection.Indexes().DropOne(ctx, name)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	result, err := coll.Client.decode(L, res)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(result)
+	return 1
+}
+
+// collectionDropIndexesMethod drops all the indexes except _id, or the indexes
+// with the given names in a single command, so that none is dropped on failure
+func collectionDropIndexesMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	var names []interface{}
+	switch lv := L.Get(2); lv.Type() {
+	case lua.LTNil:
+	case lua.LTString:
+		names = []interface{}{lua.LVAsString(lv)}
+	case lua.LTTable:
+		var ok bool
+		if names, ok = bsonutil.GetValue(L, 2).([]interface{}); !ok {
+			L.ArgError(2, "index names expected")
+			return 0
+		}
+	default:
+		L.ArgError(2, "index names expected")
+		return 0
+	}
+	_, sess := checkOptions(L, 3)
+
+	if names != nil {
+		for _, v := range names {
+			if _, ok := v.(string); !ok {
+				L.ArgError(2, "index names expected")
+				return 0
+			}
+		}
+		return runCollectionCommand(L, coll, sess, bson.D{
+			{Key: "dropIndexes", Value: coll.Collection.Name()},
+			{Key: "index", Value: bson.A(names)},
+		})
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	res, err := coll.Collection.Indexes().DropAll(ctx)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+	result, err := coll.Client.decode(L, res)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+	L.Push(result)
+	return 1
+}
+
+func collectionListIndexesMethod(L *lua.LState) int {
+	coll := checkCollection(L)
+
+	rawOpts, sess := checkOptions(L, 2)
+	m, err := optionsMap(rawOpts)
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+	opts := options.ListIndexes()
+	if v, ok := m["batchSize"]; ok {
+		i, err := toInt32(v)
+		if err != nil {
+			L.ArgError(2, "invalid batchSize option")
+			return 0
+		}
+		opts.SetBatchSize(i)
+	}
+	if v, ok := m["maxTimeMS"]; ok {
+		d, err := toDuration(v)
+		if err != nil {
+			L.ArgError(2, "invalid maxTimeMS option")
+			return 0
+		}
+		opts.SetMaxTime(d)
+	}
+
+	ctx, cancel := coll.Client.SessionContext(sess)
+	defer cancel()
+
+	cur, err := coll.Collection.Indexes().List(ctx, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	results, err := coll.Client.decodeAll(ctx, L, cur)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(results)
+	return 1
+}
+
+// setIndexHidden hides or unhides index by name or key specification with collMod
+func setIndexHidden(L *lua.LState, hidden bool) int {
+	coll := checkCollection(L)
+
+	index := checkIndexName(L, 2)
+	_, sess := checkOptions(L, 3)
+
+	spec := bson.D{{Key: "hidden", Value: hidden}}
+	if name, ok := index.(string); ok {
+		spec = append(spec, bson.E{Key: "name", Value: name})
+	} else {
+		spec = append(spec, bson.E{Key: "keyPattern", Value: index})
+	}
+	return runCollectionCommand(L, coll, sess, bson.D{
+		{Key: "collMod", Value: coll.Collection.Name()},
+		{Key: "index", Value: spec},
+	})
+}
+
+func collectionHideIndexMethod(L *lua.LState) int {
+	return setIndexHidden(L, true)
+}
+
+func collectionUnhideIndexMethod(L *lua.LState) int {
+	return setIndexHidden(L, false)
+}
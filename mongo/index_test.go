@@ -0,0 +1,67 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestIndexes(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		mcoll:dropIndexes();
+		local name, err = mcoll:createIndex({a = 1}, {unique = true, partialFilterExpression = {a = {["$exists"] = true}}});
+		local names, err2 = mcoll:createIndexes({'{"b": 1, "c": -1}', {dt = 1}, mongo.Doc({{"d", 1}, {"e", -1}})}, {expireAfterSeconds = 3600, sparse = true});
+		local ok3 = pcall(mcoll.createIndexes, mcoll, {{f = 1}, 1});
+		local ok, err3 = mcoll:hideIndex('b_1_c_-1');
+		local ok2, err4 = mcoll:unhideIndex({dt = 1});
+		local res, err5 = mcoll:dropIndex({dt = 1});
+		local res2, err6 = mcoll:dropIndex('{"d": 1, "e": -1}');
+		local res3, err7 = mcoll:dropIndexes({'b_1_c_-1', 'missing_1'});
+		local indexes = mcoll:listIndexes();
+		local res4, err8 = mcoll:dropIndexes({'b_1_c_-1'});
+		local _, dupErr = mcoll:insert({{a = 1}, {a = 1}});
+		mcoll:dropIndexes();
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return name, err, names, err2, err3, err4, err5, #indexes, dupErr:isDuplicateKey(), ok3,
+		  err6, res3, err7 ~= nil, err8
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(14, L.GetTop())
+	assert.Equal("a_1", L.ToString(1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal([]interface{}{"b_1_c_-1", "dt_1", "d_1_e_-1"}, bsonutil.GetValue(L, 3))
+	assert.Equal(lua.LNil, L.Get(4))
+	assert.Equal(lua.LNil, L.Get(5))
+	assert.Equal(lua.LNil, L.Get(6))
+	assert.Equal(lua.LNil, L.Get(7))
+	// _id, a_1 and b_1_c_-1, the failed dropIndexes drops none
+	assert.Equal(lua.LNumber(3), L.Get(8))
+	assert.Equal(lua.LTrue, L.Get(9))
+	assert.Equal(lua.LFalse, L.Get(10))
+	assert.Equal(lua.LNil, L.Get(11))
+	assert.Equal(lua.LNil, L.Get(12))
+	assert.Equal(lua.LTrue, L.Get(13))
+	assert.Equal(lua.LNil, L.Get(14))
+}
@@ -6,15 +6,28 @@ import (
 )
 
 var exports = map[string]lua.LGFunction{
-	"Client":    newClient,
-	"ObjectID":  bsonutil.NewObjectID,
-	"DateTime":  bsonutil.NewDateTime,
-	"Timestamp": bsonutil.NewTimestamp,
+	"Client":        newClient,
+	"ObjectID":      bsonutil.NewObjectID,
+	"DateTime":      bsonutil.NewDateTime,
+	"Timestamp":     bsonutil.NewTimestamp,
+	"Doc":           bsonutil.NewDoc,
+	"Int32":         bsonutil.NewInt32,
+	"Int64":         bsonutil.NewInt64,
+	"Double":        bsonutil.NewDouble,
+	"Decimal128":    bsonutil.NewDecimal128,
+	"Binary":        bsonutil.NewBinary,
+	"UUID":          bsonutil.NewUUID,
+	"Regex":         bsonutil.NewRegex,
+	"Code":          bsonutil.NewCode,
+	"CodeWithScope": bsonutil.NewCodeWithScope,
+	"DBPointer":     bsonutil.NewDBPointer,
+	"Symbol":        bsonutil.NewSymbol,
 }
 
 // Loader mongo module loader
 func Loader(L *lua.LState) int {
 	mod := L.SetFuncs(L.NewTable(), exports)
+	bsonutil.SetTypeFuncs(L, mod)
 	L.Push(mod)
 
 	L.SetField(mod, "_DEBUG", lua.LBool(false))
@@ -22,6 +35,9 @@ func Loader(L *lua.LState) int {
 
 	// consts, after type registered
 	L.SetField(mod, "Null", bsonutil.LNull(L))
+	L.SetField(mod, "MinKey", bsonutil.LMinKey(L))
+	L.SetField(mod, "MaxKey", bsonutil.LMaxKey(L))
+	L.SetField(mod, "Undefined", bsonutil.LUndefined(L))
 
 	return 1
 }
@@ -34,6 +50,28 @@ func RegisterType(L *lua.LState) {
 	L.SetField(mtClient, "__index", L.SetFuncs(L.NewTable(), clientMethods))
 	mtCollection := L.NewTypeMetatable(COLLECTION_TYPENAME)
 	L.SetField(mtCollection, "__index", L.SetFuncs(L.NewTable(), collectionMethods))
+	mtCursor := L.NewTypeMetatable(CURSOR_TYPENAME)
+	L.SetField(mtCursor, "__index", L.SetFuncs(L.NewTable(), cursorMethods))
+	L.SetField(mtCursor, "__call", L.NewFunction(cursorCallMethod))
 	mtDatabase := L.NewTypeMetatable(DATABASE_TYPENAME)
 	L.SetField(mtDatabase, "__index", L.SetFuncs(L.NewTable(), databaseMethods))
+	mtSession := L.NewTypeMetatable(SESSION_TYPENAME)
+	L.SetField(mtSession, "__index", L.SetFuncs(L.NewTable(), sessionMethods))
+	mtBulk := L.NewTypeMetatable(BULK_TYPENAME)
+	L.SetField(mtBulk, "__index", L.SetFuncs(L.NewTable(), bulkMethods))
+	mtBulkFind := L.NewTypeMetatable(BULKFIND_TYPENAME)
+	L.SetField(mtBulkFind, "__index", L.SetFuncs(L.NewTable(), bulkFindMethods))
+	mtError := L.NewTypeMetatable(ERROR_TYPENAME)
+	L.SetField(mtError, "__index", L.NewFunction(errorIndexMethod))
+	L.SetField(mtError, "__tostring", L.NewFunction(errorToStringMethod))
+	L.SetField(mtError, "__concat", L.NewFunction(errorConcatMethod))
+	mtChangeStream := L.NewTypeMetatable(CHANGESTREAM_TYPENAME)
+	L.SetField(mtChangeStream, "__index", L.SetFuncs(L.NewTable(), changeStreamMethods))
+	L.SetField(mtChangeStream, "__call", L.NewFunction(changeStreamCallMethod))
+	mtGridFSBucket := L.NewTypeMetatable(GRIDFSBUCKET_TYPENAME)
+	L.SetField(mtGridFSBucket, "__index", L.SetFuncs(L.NewTable(), gridfsBucketMethods))
+	mtGridFSUploadStream := L.NewTypeMetatable(GRIDFSUPLOADSTREAM_TYPENAME)
+	L.SetField(mtGridFSUploadStream, "__index", L.SetFuncs(L.NewTable(), gridfsUploadStreamMethods))
+	mtGridFSDownloadStream := L.NewTypeMetatable(GRIDFSDOWNLOADSTREAM_TYPENAME)
+	L.SetField(mtGridFSDownloadStream, "__index", L.SetFuncs(L.NewTable(), gridfsDownloadStreamMethods))
 }
@@ -0,0 +1,231 @@
+package gluamongo_mongo
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// optionsMap normalizes map and ordered (bson.D) options
+func optionsMap(opts interface{}) (map[string]interface{}, error) {
+	switch m := opts.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return m, nil
+	case bson.D:
+		res := make(map[string]interface{}, len(m))
+		for _, v := range m {
+			res[v.Key] = v.Value
+		}
+		return res, nil
+	case []interface{}:
+		if len(m) == 0 {
+			return map[string]interface{}{}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid options: %v (%T)", opts, opts)
+}
+
+func toNumber(v interface{}) (int64, error) {
+	switch i := v.(type) {
+	case int64:
+		return i, nil
+	case int:
+		return int64(i), nil
+	case int32:
+		return int64(i), nil
+	case float64:
+		return int64(i), nil
+	}
+	return 0, fmt.Errorf("unknown value: %v (%T)", v, v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch f := v.(type) {
+	case float64:
+		return f, nil
+	case int:
+		return float64(f), nil
+	case int32:
+		return float64(f), nil
+	case int64:
+		return float64(f), nil
+	}
+	return 0, fmt.Errorf("unknown value: %v (%T)", v, v)
+}
+
+func toInt32(v interface{}) (int32, error) {
+	i, err := toNumber(v)
+	return int32(i), err
+}
+
+func toBool(v interface{}) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("unknown value: %v (%T)", v, v)
+}
+
+func toString(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown value: %v (%T)", v, v)
+}
+
+// toDuration converts milliseconds to duration
+func toDuration(v interface{}) (time.Duration, error) {
+	i, err := toNumber(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(i) * time.Millisecond, nil
+}
+
+func toCollation(v interface{}) (*options.Collation, error) {
+	m, err := optionsMap(v)
+	if err != nil {
+		return nil, err
+	}
+	c := &options.Collation{}
+	for key, val := range m {
+		var err error
+		switch key {
+		case "locale":
+			c.Locale, err = toString(val)
+		case "caseLevel":
+			c.CaseLevel, err = toBool(val)
+		case "caseFirst":
+			c.CaseFirst, err = toString(val)
+		case "strength":
+			var i int64
+			i, err = toNumber(val)
+			c.Strength = int(i)
+		case "numericOrdering":
+			c.NumericOrdering, err = toBool(val)
+		case "alternate":
+			c.Alternate, err = toString(val)
+		case "maxVariable":
+			c.MaxVariable, err = toString(val)
+		case "normalization":
+			c.Normalization, err = toBool(val)
+		case "backwards":
+			c.Backwards, err = toBool(val)
+		default:
+			err = fmt.Errorf("unknown collation option: %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Locale == "" {
+		return nil, fmt.Errorf("collation locale required")
+	}
+	return c, nil
+}
+
+// toReadConcern accepts level string or {level = ...}
+func toReadConcern(v interface{}) (*readconcern.ReadConcern, error) {
+	if level, ok := v.(string); ok {
+		return readconcern.New(readconcern.Level(level)), nil
+	}
+	m, err := optionsMap(v)
+	if err != nil {
+		return nil, err
+	}
+	level, err := toString(m["level"])
+	if err != nil {
+		return nil, err
+	}
+	return readconcern.New(readconcern.Level(level)), nil
+}
+
+// toWriteConcern accepts w value or {w = ..., j = ..., wtimeout = ...}
+func toWriteConcern(v interface{}) (*writeconcern.WriteConcern, error) {
+	m, err := optionsMap(v)
+	if err != nil {
+		m = map[string]interface{}{"w": v}
+	}
+	var opts []writeconcern.Option
+	for key, val := range m {
+		switch key {
+		case "w":
+			if str, ok := val.(string); ok {
+				if str == "majority" {
+					opts = append(opts, writeconcern.WMajority())
+				} else {
+					opts = append(opts, writeconcern.WTagSet(str))
+				}
+			} else {
+				var w int64
+				if w, err = toNumber(val); err == nil {
+					opts = append(opts, writeconcern.W(int(w)))
+				}
+			}
+		case "j":
+			var j bool
+			if j, err = toBool(val); err == nil {
+				opts = append(opts, writeconcern.J(j))
+			}
+		case "wtimeout":
+			var d time.Duration
+			if d, err = toDuration(val); err == nil {
+				opts = append(opts, writeconcern.WTimeout(d))
+			}
+		default:
+			err = fmt.Errorf("unknown write concern option: %s", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return writeconcern.New(opts...), nil
+}
+
+// toReadPref accepts mode string or {mode = ..., maxStalenessSeconds = ...}
+func toReadPref(v interface{}) (*readpref.ReadPref, error) {
+	if mode, ok := v.(string); ok {
+		m, err := readpref.ModeFromString(mode)
+		if err != nil {
+			return nil, err
+		}
+		return readpref.New(m)
+	}
+	m, err := optionsMap(v)
+	if err != nil {
+		return nil, err
+	}
+	str, err := toString(m["mode"])
+	if err != nil {
+		return nil, err
+	}
+	mode, err := readpref.ModeFromString(str)
+	if err != nil {
+		return nil, err
+	}
+	var opts []readpref.Option
+	if val, ok := m["maxStalenessSeconds"]; ok {
+		i, err := toNumber(val)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, readpref.WithMaxStaleness(time.Duration(i)*time.Second))
+	}
+	return readpref.New(mode, opts...)
+}
+
+func toArrayFilters(v interface{}) (options.ArrayFilters, error) {
+	switch filters := v.(type) {
+	case []interface{}:
+		return options.ArrayFilters{Filters: filters}, nil
+	case bson.A:
+		return options.ArrayFilters{Filters: filters}, nil
+	}
+	return options.ArrayFilters{}, fmt.Errorf("unknown value: %v (%T)", v, v)
+}
@@ -0,0 +1,281 @@
+package gluamongo_mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	SESSION_TYPENAME = "mongo{session}"
+)
+
+// Session mongo
+type Session struct {
+	Client  *Client
+	Session mongo.Session
+}
+
+var sessionMethods = map[string]lua.LGFunction{
+	"abortTransaction":  sessionAbortTransactionMethod,
+	"commitTransaction": sessionCommitTransactionMethod,
+	"endSession":        sessionEndSessionMethod,
+	"startTransaction":  sessionStartTransactionMethod,
+	"withTransaction":   sessionWithTransactionMethod,
+}
+
+func pushSession(L *lua.LState, client *Client, session mongo.Session) {
+	ud := L.NewUserData()
+	ud.Value = &Session{
+		Client:  client,
+		Session: session,
+	}
+	L.SetMetatable(ud, L.GetTypeMetatable(SESSION_TYPENAME))
+	L.Push(ud)
+}
+
+func checkSession(L *lua.LState) *Session {
+	ud := L.CheckUserData(1)
+	if v, ok := ud.Value.(*Session); ok {
+		return v
+	}
+	L.ArgError(1, "mongo session expected")
+	return nil
+}
+
+// checkOptions converts options at idx, the session option is taken out
+// of the table before converting as it is not a bson value, allow nil
+func checkOptions(L *lua.LState, idx int) (interface{}, *Session) {
+	tb, ok := L.Get(idx).(*lua.LTable)
+	if !ok {
+		return bsonutil.ToBSON(L, idx), nil
+	}
+	lv := tb.RawGetString("session")
+	if lv == lua.LNil {
+		return bsonutil.ToBSON(L, idx), nil
+	}
+	var sess *Session
+	if ud, ok := lv.(*lua.LUserData); ok {
+		sess, _ = ud.Value.(*Session)
+	}
+	if sess == nil {
+		L.ArgError(idx, fmt.Sprintf("invalid session option: %s", lv.Type()))
+		return nil, nil
+	}
+
+	opts := L.NewTable()
+	tb.ForEach(func(k, v lua.LValue) {
+		if k != lua.LString("session") {
+			opts.RawSet(k, v)
+		}
+	})
+	L.Replace(idx, opts)
+	defer L.Replace(idx, tb)
+	return bsonutil.ToBSON(L, idx), sess
+}
+
+func sessionOptions(opts interface{}) (*options.SessionOptions, error) {
+	so := options.Session()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "causalConsistency":
+			var b bool
+			if b, err = toBool(v); err == nil {
+				so.SetCausalConsistency(b)
+			}
+		case "defaultMaxCommitTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				so.SetDefaultMaxCommitTime(&d)
+			}
+		case "defaultReadConcern":
+			rc, err2 := toReadConcern(v)
+			if err = err2; err == nil {
+				so.SetDefaultReadConcern(rc)
+			}
+		case "defaultReadPreference":
+			rp, err2 := toReadPref(v)
+			if err = err2; err == nil {
+				so.SetDefaultReadPreference(rp)
+			}
+		case "defaultWriteConcern":
+			wc, err2 := toWriteConcern(v)
+			if err = err2; err == nil {
+				so.SetDefaultWriteConcern(wc)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return so, nil
+}
+
+func transactionOptions(opts interface{}) (*options.TransactionOptions, error) {
+	to := options.Transaction()
+	m, err := optionsMap(opts)
+	if err != nil {
+		return nil, err
+	}
+	for key, v := range m {
+		switch key {
+		case "maxCommitTimeMS":
+			var d time.Duration
+			if d, err = toDuration(v); err == nil {
+				to.SetMaxCommitTime(&d)
+			}
+		case "readConcern":
+			rc, err2 := toReadConcern(v)
+			if err = err2; err == nil {
+				to.SetReadConcern(rc)
+			}
+		case "readPreference":
+			rp, err2 := toReadPref(v)
+			if err = err2; err == nil {
+				to.SetReadPreference(rp)
+			}
+		case "writeConcern":
+			wc, err2 := toWriteConcern(v)
+			if err = err2; err == nil {
+				to.SetWriteConcern(wc)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s option: %v", key, err)
+		}
+	}
+	return to, nil
+}
+
+// luaError converts glua error value to go error, mongo errors are unwrapped
+// so that the error labels are kept
+func luaError(lv lua.LValue) error {
+	if ud, ok := lv.(*lua.LUserData); ok {
+		if e, ok := ud.Value.(*Error); ok {
+			return e.Err
+		}
+	}
+	return errors.New(lv.String())
+}
+
+func sessionAbortTransactionMethod(L *lua.LState) int {
+	s := checkSession(L)
+
+	ctx, cancel := s.Client.Context()
+	defer cancel()
+
+	err := s.Session.AbortTransaction(ctx)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func sessionCommitTransactionMethod(L *lua.LState) int {
+	s := checkSession(L)
+
+	ctx, cancel := s.Client.Context()
+	defer cancel()
+
+	err := s.Session.CommitTransaction(ctx)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func sessionEndSessionMethod(L *lua.LState) int {
+	s := checkSession(L)
+
+	ctx, cancel := s.Client.Context()
+	defer cancel()
+
+	s.Session.EndSession(ctx)
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+func sessionStartTransactionMethod(L *lua.LState) int {
+	s := checkSession(L)
+
+	opts, err := transactionOptions(bsonutil.ToBSON(L, 2))
+	if err != nil {
+		L.ArgError(2, err.Error())
+		return 0
+	}
+
+	err = s.Session.StartTransaction(opts)
+	if err != nil {
+		L.Push(lua.LBool(false))
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	L.Push(lua.LBool(true))
+	return 1
+}
+
+// sessionWithTransactionMethod runs fn(session) in a transaction, the whole
+// transaction is retried on TransientTransactionError labels and the commit is
+// retried on UnknownTransactionCommitResult labels
+func sessionWithTransactionMethod(L *lua.LState) int {
+	s := checkSession(L)
+	fn := L.CheckFunction(2)
+
+	opts, err := transactionOptions(bsonutil.ToBSON(L, 3))
+	if err != nil {
+		L.ArgError(3, err.Error())
+		return 0
+	}
+
+	// retries are bounded by the driver (120 seconds), each operation in fn
+	// still uses the client timeout
+	ud := L.Get(1)
+	res, err := s.Session.WithTransaction(context.Background(), func(sessCtx mongo.SessionContext) (interface{}, error) {
+		L.Push(fn)
+		L.Push(ud)
+		if err := L.PCall(1, 2, nil); err != nil {
+			if apiErr, ok := err.(*lua.ApiError); ok {
+				return nil, luaError(apiErr.Object)
+			}
+			return nil, err
+		}
+		ret, retErr := L.Get(-2), L.Get(-1)
+		L.Pop(2)
+		if retErr != lua.LNil {
+			return nil, luaError(retErr)
+		}
+		return ret, nil
+	}, opts)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(LError(L, err))
+		return 2
+	}
+
+	if lv, ok := res.(lua.LValue); ok {
+		L.Push(lv)
+	} else {
+		L.Push(lua.LNil)
+	}
+	return 1
+}
@@ -0,0 +1,60 @@
+package gluamongo_mongo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gluamongo "github.com/tengattack/gluamongo"
+	"github.com/tengattack/gluamongo/bsonutil"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestSessionWithTransaction(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// test start
+	L := lua.NewState()
+	defer L.Close()
+	gluamongo.Preload(L)
+
+	script := getLuaMongoConnection() + `
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local mcoll, err = mongoClient:getCollection('test', 'test');
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		mcoll:remove({}); -- remove all
+		local session, err = mongoClient:startSession();
+		if err ~= nil then
+		  error(tostring(err));
+		end
+		local res, err = session:withTransaction(function(s)
+		  local _, err = mcoll:insert({a = 1}, {session = s});
+		  if err ~= nil then
+		    return nil, err;
+		  end
+		  return mcoll:count({a = 1}, {session = s});
+		end);
+		local res2, err2 = session:withTransaction(function(s)
+		  mcoll:insert({a = 2}, {session = s});
+		  error('rollback');
+		end);
+		session:endSession();
+		local res3 = mcoll:count({});
+		mcoll:remove({});
+		mongoClient:disconnect();
+		return res, err, res2, tostring(err2), res3
+	`
+
+	require.NoError(L.DoString(script))
+	require.Equal(5, L.GetTop())
+	assert.Equal(1, bsonutil.GetValue(L, 1))
+	assert.Equal(lua.LNil, L.Get(2))
+	assert.Equal(lua.LNil, L.Get(3))
+	assert.Contains(L.ToString(4), "rollback")
+	assert.Equal(1, bsonutil.GetValue(L, 5))
+}